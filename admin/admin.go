@@ -0,0 +1,298 @@
+// Package admin provides an HTTP API to inspect and manage a running votifier.Server.
+//
+// All requests must carry the configured bearer token in the Authorization
+// header. The handler serves the following endpoints, relative to where it
+// is mounted (use http.StripPrefix to mount it under a path):
+//
+//	GET    /records                 configured records and key ring usage, without secrets
+//	GET    /publickey?record=0      active v1 public key in base64, for vote sites
+//	GET    /votes                   recently accepted votes, newest first
+//	GET    /stats                   server counters
+//	GET    /tokens                  services with a v2 token
+//	PUT    /tokens/{service}        set a token, {"token": "..."}, generated if empty
+//	POST   /tokens/{service}/rotate replace a token with a generated one, the
+//	                                previous token stays valid for ?grace=24h
+//	DELETE /tokens/{service}        remove a token
+//
+// Token endpoints respond with the token that was set and its TokenID, so
+// it can be copied to the vote site; tokens are never listed. Accepted
+// votes list the TokenID of the token that verified them, telling when a
+// vote site switched to a rotated token.
+package admin
+
+import (
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+// Vote is an accepted vote as listed by the API.
+type Vote struct {
+	votifier.Vote
+	Protocol   votifier.Protocol `json:"protocol"`
+	RemoteAddr string            `json:"remoteAddr,omitempty"`
+	KeyID      string            `json:"keyId,omitempty"`   // Key ring key that decrypted a v1 vote.
+	TokenID    string            `json:"tokenId,omitempty"` // Token version that verified a v2 vote.
+	Received   time.Time         `json:"received"`
+}
+
+// Record describes a votifier.ReceiverRecord without its secrets.
+type Record struct {
+	Index     int                `json:"index"`
+	V1        bool               `json:"v1"`                  // The record has a private key.
+	PublicKey string             `json:"publicKey,omitempty"` // Base64 encoded public key of the active key.
+	KeyBits   int                `json:"keyBits,omitempty"`
+	Keys      []votifier.KeyInfo `json:"keys,omitempty"`     // Keys and their usage, if the record has a key ring.
+	V2        bool               `json:"v2"`                 // The record has a token provider.
+	Services  []string           `json:"services,omitempty"` // Services with a token, if the provider is a *votifier.MapTokenProvider.
+}
+
+// Handler is an http.Handler serving the admin API.
+type Handler struct {
+	Server      *votifier.Server           // Required, the server to inspect.
+	Tokens      *votifier.MapTokenProvider // Optional, enables the token endpoints.
+	BearerToken string                     // Required, requests without it are rejected.
+	RecentVotes int                        // Number of recent votes kept, defaults to 100.
+
+	mu     sync.Mutex
+	recent []Vote // ring buffer
+	next   int
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// Listener returns a listener recording accepted votes for the /votes endpoint
+// and passing them on to next. It must be the server's VoteInfoHandler.
+func (h *Handler) Listener(next votifier.VoteInfoListener) votifier.VoteInfoListener {
+	return func(vote *votifier.Vote, info *votifier.VoteInfo) error {
+		if err := next(vote, info); err != nil {
+			return err
+		}
+		v := Vote{Vote: *vote, Protocol: info.Protocol, KeyID: info.KeyID, TokenID: info.TokenID, Received: time.Now()}
+		if info.RemoteAddr != nil {
+			v.RemoteAddr = info.RemoteAddr.String()
+		}
+		h.record(v)
+		return nil
+	}
+}
+
+func (h *Handler) record(v Vote) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	size := h.RecentVotes
+	if size <= 0 {
+		size = 100
+	}
+	if len(h.recent) < size {
+		h.recent = append(h.recent, v)
+		return
+	}
+	h.recent[h.next] = v
+	h.next = (h.next + 1) % len(h.recent)
+}
+
+// votes returns the recent votes, newest first.
+func (h *Handler) votes() []Vote {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	votes := make([]Vote, 0, len(h.recent))
+	for i := len(h.recent) - 1; i >= 0; i-- {
+		votes = append(votes, h.recent[(h.next+i)%len(h.recent)])
+	}
+	return votes
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="votifier"`)
+		writeError(w, http.StatusUnauthorized, errors.New("invalid bearer token"))
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "records":
+		h.get(w, r, h.records)
+	case path == "publickey":
+		h.publicKey(w, r)
+	case path == "votes":
+		h.get(w, r, func() (any, error) { return h.votes(), nil })
+	case path == "stats":
+		h.get(w, r, func() (any, error) { return h.Server.Stats(), nil })
+	case path == "tokens" || strings.HasPrefix(path, "tokens/"):
+		h.tokens(w, r, strings.TrimPrefix(strings.TrimPrefix(path, "tokens"), "/"))
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := auth[len("Bearer "):]
+	return h.BearerToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.BearerToken)) == 1
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, f func() (any, error)) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	v, err := f()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (h *Handler) records() (any, error) {
+	records := make([]Record, 0, len(h.Server.Records))
+	for i, rec := range h.Server.Records {
+		active := rec.ActiveKey()
+		r := Record{Index: i, V1: active != nil, V2: rec.TokenProvider != nil}
+		if active != nil {
+			key, err := votifier.EncodePublicKey(&active.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			r.PublicKey = key
+			r.KeyBits = active.N.BitLen()
+		}
+		if rec.KeyRing != nil {
+			r.Keys = rec.KeyRing.Keys()
+		}
+		if p, ok := rec.TokenProvider.(*votifier.MapTokenProvider); ok {
+			r.Services = p.Services()
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+func (h *Handler) publicKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	index := -1
+	if s := r.URL.Query().Get("record"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 0 || i >= len(h.Server.Records) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid record %q", s))
+			return
+		}
+		index = i
+	} else {
+		// Default to the first record with a key.
+		for i := range h.Server.Records {
+			if h.Server.Records[i].ActiveKey() != nil {
+				index = i
+				break
+			}
+		}
+	}
+	var active *rsa.PrivateKey
+	if index >= 0 {
+		active = h.Server.Records[index].ActiveKey()
+	}
+	if active == nil {
+		writeError(w, http.StatusNotFound, errors.New("record has no v1 key"))
+		return
+	}
+	key, err := votifier.EncodePublicKey(&active.PublicKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprintln(w, key)
+}
+
+type tokenResponse struct {
+	Service string `json:"service"`
+	Token   string `json:"token"`
+	ID      string `json:"id"` // TokenID of the token.
+}
+
+func (h *Handler) tokens(w http.ResponseWriter, r *http.Request, path string) {
+	if h.Tokens == nil {
+		writeError(w, http.StatusNotFound, errors.New("token management is not enabled"))
+		return
+	}
+	service, action := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		service, action = path[:i], path[i+1:]
+	}
+
+	switch {
+	case service == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.Tokens.Services())
+	case service != "" && action == "" && r.Method == http.MethodPut:
+		var req struct {
+			Token string `json:"token"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		h.setToken(w, service, req.Token, 0)
+	case service != "" && action == "rotate" && r.Method == http.MethodPost:
+		var grace time.Duration
+		if s := r.URL.Query().Get("grace"); s != "" {
+			var err error
+			if grace, err = time.ParseDuration(s); err != nil || grace < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid grace period %q", s))
+				return
+			}
+		}
+		h.setToken(w, service, "", grace)
+	case service != "" && action == "" && r.Method == http.MethodDelete:
+		if !h.Tokens.Delete(service) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("service %q has no token", service))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// setToken sets the token of the service, generating one if token is empty.
+// The previous token stays valid for the grace period.
+func (h *Handler) setToken(w http.ResponseWriter, service, token string, grace time.Duration) {
+	if token == "" {
+		var err error
+		if token, err = votifier.NewToken(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	h.Tokens.Rotate(service, token, grace)
+	writeJSON(w, http.StatusOK, tokenResponse{Service: service, Token: token, ID: votifier.TokenID(token)})
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
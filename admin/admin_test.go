@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+func TestHandler(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := votifier.NewMapTokenProvider(map[string]string{"old-site": "old-secret"})
+	server := &votifier.Server{
+		Records: []votifier.ReceiverRecord{{PrivateKey: key, TokenProvider: tokens}},
+	}
+	h := &Handler{Server: server, Tokens: tokens, BearerToken: "admin"}
+	server.VoteInfoHandler = h.Listener(func(*votifier.Vote, *votifier.VoteInfo) error { return nil })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go server.Serve(ln) //nolint:errcheck
+
+	api := httptest.NewServer(h)
+	defer api.Close()
+	call := func(method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer admin")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+
+	// Unauthorized requests are rejected.
+	for _, auth := range []string{"", "admin", "Basic admin", "Bearer wrong"} {
+		req, err := http.NewRequest(http.MethodGet, api.URL+"/stats", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%q: expected unauthorized, got %d", auth, res.StatusCode)
+		}
+	}
+
+	// Add a token at runtime and vote with it.
+	status, body := call(http.MethodPut, "/tokens/site", `{"token":"secret"}`)
+	if status != http.StatusOK || !strings.Contains(body, `"token":"secret"`) {
+		t.Fatalf("unexpected response to setting token: %d %s", status, body)
+	}
+	if err = votifier.NewV2Client(ln.Addr().String(), "secret").SendVote(votifier.Vote{ServiceName: "site", Username: "golang"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotating invalidates the old token.
+	status, body = call(http.MethodPost, "/tokens/site/rotate", "")
+	var rotated tokenResponse
+	if err = json.Unmarshal([]byte(body), &rotated); status != http.StatusOK || err != nil || rotated.Token == "secret" {
+		t.Fatalf("unexpected response to rotating token: %d %s", status, body)
+	}
+	if err = votifier.NewV2Client(ln.Addr().String(), "secret").SendVote(votifier.Vote{ServiceName: "site", Username: "golang"}); err == nil {
+		t.Error("expected vote with rotated token to fail")
+	}
+
+	if status, _ = call(http.MethodDelete, "/tokens/old-site", ""); status != http.StatusNoContent {
+		t.Errorf("expected token to be deleted, got %d", status)
+	}
+	if status, body = call(http.MethodGet, "/tokens", ""); strings.TrimSpace(body) != `["site"]` {
+		t.Errorf("unexpected services %d %s", status, body)
+	}
+
+	_, body = call(http.MethodGet, "/records", "")
+	if strings.Contains(body, rotated.Token) || !strings.Contains(body, `"services":["site"]`) {
+		t.Errorf("unexpected records %s", body)
+	}
+
+	_, body = call(http.MethodGet, "/publickey", "")
+	pub, err := votifier.ParsePublicKey(body)
+	if err != nil || !pub.Equal(&key.PublicKey) {
+		t.Errorf("unexpected public key %q: %v", body, err)
+	}
+
+	_, body = call(http.MethodGet, "/votes", "")
+	var votes []Vote
+	if err = json.Unmarshal([]byte(body), &votes); err != nil || len(votes) != 1 || votes[0].Username != "golang" || votes[0].Protocol != votifier.V2 {
+		t.Errorf("unexpected votes %s: %v", body, err)
+	}
+
+	_, body = call(http.MethodGet, "/stats", "")
+	var stats votifier.ServerStats
+	if err = json.Unmarshal([]byte(body), &stats); err != nil || stats.Votes != 1 || stats.Connections != 2 {
+		t.Errorf("unexpected stats %s: %v", body, err)
+	}
+}
+
+func TestRecentVotes(t *testing.T) {
+	h := &Handler{RecentVotes: 3}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		h.record(Vote{Vote: votifier.Vote{Username: name}})
+	}
+	var got []string
+	for _, v := range h.votes() {
+		got = append(got, v.Username)
+	}
+	if strings.Join(got, "") != "edc" {
+		t.Errorf("expected newest 3 votes first, got %v", got)
+	}
+}
+
+func TestKeyRingRecord(t *testing.T) {
+	var keys []*rsa.PrivateKey
+	for i := 0; i < 2; i++ {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	ring := votifier.NewKeyRing("old", keys[0])
+	if err := ring.Rotate("new", keys[1], time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{
+		Server:      &votifier.Server{Records: []votifier.ReceiverRecord{{KeyRing: ring}}},
+		BearerToken: "admin",
+	}
+	get := func(path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", path, rec.Code, rec.Body)
+		}
+		return rec.Body.String()
+	}
+
+	want, err := votifier.EncodePublicKey(&keys[1].PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(get("/publickey")); got != want {
+		t.Errorf("expected the active public key, got %s", got)
+	}
+	var records []Record
+	if err = json.Unmarshal([]byte(get("/records")), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].V1 || records[0].PublicKey != want || len(records[0].Keys) != 2 || records[0].Keys[1].ID != "old" {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+func TestRotateTokenWithGrace(t *testing.T) {
+	tokens := votifier.NewMapTokenProvider(map[string]string{"site": "old"})
+	server := &votifier.Server{Records: []votifier.ReceiverRecord{{TokenProvider: tokens}}}
+	h := &Handler{Server: server, Tokens: tokens, BearerToken: "admin"}
+	server.VoteInfoHandler = h.Listener(func(*votifier.Vote, *votifier.VoteInfo) error { return nil })
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go server.Serve(ln) //nolint:errcheck
+
+	call := func(method, path string) (int, string) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+	if status, _ := call(http.MethodPost, "/tokens/site/rotate?grace=soon"); status != http.StatusBadRequest {
+		t.Errorf("expected bad request for an invalid grace period, got %d", status)
+	}
+	status, body := call(http.MethodPost, "/tokens/site/rotate?grace=1h")
+	var rotated tokenResponse
+	if err = json.Unmarshal([]byte(body), &rotated); status != http.StatusOK || err != nil || rotated.ID != votifier.TokenID(rotated.Token) {
+		t.Fatalf("unexpected response to rotating token: %d %s", status, body)
+	}
+
+	// Both tokens are accepted during the grace period.
+	for _, token := range []string{"old", rotated.Token} {
+		if err = votifier.NewV2Client(ln.Addr().String(), token).SendVote(votifier.Vote{ServiceName: "site", Username: "golang"}); err != nil {
+			t.Fatalf("vote with token %q: %v", token, err)
+		}
+	}
+	_, body = call(http.MethodGet, "/votes")
+	var votes []Vote
+	if err = json.Unmarshal([]byte(body), &votes); err != nil || len(votes) != 2 {
+		t.Fatalf("unexpected votes %s: %v", body, err)
+	}
+	if votes[0].TokenID != rotated.ID || votes[1].TokenID != votifier.TokenID("old") {
+		t.Errorf("expected votes to report the token version, got %+v", votes)
+	}
+}
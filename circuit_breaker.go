@@ -0,0 +1,162 @@
+package votifier
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a CircuitBreaker that fails fast.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+// Circuit breaker states.
+const (
+	BreakerClosed   BreakerState = iota // Votes are sent normally.
+	BreakerOpen                         // Votes fail fast with ErrCircuitOpen.
+	BreakerHalfOpen                     // A single probe vote is sent to test the target.
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker is a Client that stops sending votes to an unhealthy target.
+//
+// The breaker opens after FailureThreshold consecutive failures and then fails
+// fast with ErrCircuitOpen instead of waiting for the target to time out.
+// Once OpenTimeout elapsed, the next vote is sent as a probe (half-open):
+// if it succeeds the breaker closes again, otherwise it stays open for
+// another OpenTimeout.
+type CircuitBreaker struct {
+	Client           Client                      // Required client to protect
+	FailureThreshold int                         // Consecutive failures before opening, defaults to 5.
+	OpenTimeout      time.Duration               // How long to fail fast before probing, defaults to 30 seconds.
+	OnStateChange    func(from, to BreakerState) // Optional state change handler
+
+	mu       sync.Mutex
+	state    BreakerState
+	gen      uint64 // incremented on every state change
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && timeNow().Sub(b.openedAt) >= b.openTimeout() {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// SendVote sends the vote through the underlying client unless the breaker is open.
+func (b *CircuitBreaker) SendVote(vote Vote) error {
+	c, err := b.before()
+	if err != nil {
+		return err
+	}
+	err = b.Client.SendVote(vote)
+	b.after(c, err)
+	return err
+}
+
+// breakerCall is a call let through by the breaker.
+type breakerCall struct {
+	gen   uint64 // generation of the state the call started in
+	probe bool   // whether the call is the half-open probe
+}
+
+func (b *CircuitBreaker) before() (breakerCall, error) {
+	b.mu.Lock()
+	from := b.state
+	var probe bool
+	switch b.state {
+	case BreakerOpen:
+		if timeNow().Sub(b.openedAt) < b.openTimeout() {
+			b.mu.Unlock()
+			return breakerCall{}, ErrCircuitOpen
+		}
+		b.setStateLocked(BreakerHalfOpen)
+		b.probing, probe = true, true
+	case BreakerHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			return breakerCall{}, ErrCircuitOpen
+		}
+		b.probing, probe = true, true
+	}
+	c := breakerCall{gen: b.gen, probe: probe}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+	return c, nil
+}
+
+func (b *CircuitBreaker) after(c breakerCall, err error) {
+	b.mu.Lock()
+	if c.gen != b.gen {
+		// The state changed while the call was in flight, e.g. a slow call
+		// started while closed must not close an open breaker.
+		b.mu.Unlock()
+		return
+	}
+	from := b.state
+	switch {
+	case c.probe:
+		b.probing = false
+		if err == nil {
+			b.failures = 0
+			b.setStateLocked(BreakerClosed)
+		} else {
+			b.setStateLocked(BreakerOpen)
+		}
+	case err == nil:
+		b.failures = 0
+	default:
+		b.failures++
+		threshold := b.FailureThreshold
+		if threshold <= 0 {
+			threshold = 5
+		}
+		if b.failures >= threshold {
+			b.setStateLocked(BreakerOpen)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+func (b *CircuitBreaker) setStateLocked(state BreakerState) {
+	if state == BreakerOpen {
+		b.openedAt = timeNow()
+	}
+	b.state = state
+	b.gen++
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return b.OpenTimeout
+}
+
+func (b *CircuitBreaker) notify(from, to BreakerState) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
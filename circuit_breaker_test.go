@@ -0,0 +1,142 @@
+package votifier
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var mu sync.Mutex
+	var healthy bool
+	var calls int
+	var transitions []string
+
+	b := &CircuitBreaker{
+		Client: ClientFunc(func(Vote) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			if !healthy {
+				return errors.New("target down")
+			}
+			return nil
+		}),
+		FailureThreshold: 3,
+		OpenTimeout:      50 * time.Millisecond,
+		OnStateChange: func(from, to BreakerState) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, fmt.Sprintf("%s->%s", from, to))
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := b.SendVote(Vote{}); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected target error, got %v", err)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", b.State())
+	}
+	if err := b.SendVote(Vote{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected open breaker to not call client, got %d calls", calls)
+	}
+
+	// Failed probe reopens the breaker.
+	time.Sleep(60 * time.Millisecond)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("expected half-open breaker, got %s", b.State())
+	}
+	if err := b.SendVote(Vote{}); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected probe to fail with target error, got %v", err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", b.State())
+	}
+
+	// Successful probe closes the breaker.
+	time.Sleep(60 * time.Millisecond)
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+	if err := b.SendVote(Vote{}); err != nil {
+		t.Fatal(err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", b.State())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if fmt.Sprint(transitions) != fmt.Sprint(want) {
+		t.Errorf("expected transitions %v, got %v", want, transitions)
+	}
+}
+
+func TestCircuitBreakerIgnoresStaleCalls(t *testing.T) {
+	var mu sync.Mutex
+	blocked := map[string]chan error{}
+	block := func(name string) chan error {
+		mu.Lock()
+		defer mu.Unlock()
+		blocked[name] = make(chan error)
+		return blocked[name]
+	}
+	started := make(chan string)
+	b := &CircuitBreaker{
+		Client: ClientFunc(func(v Vote) error {
+			mu.Lock()
+			release := blocked[v.Username]
+			mu.Unlock()
+			if release == nil {
+				return errors.New("target down")
+			}
+			started <- v.Username
+			return <-release
+		}),
+		FailureThreshold: 1,
+		OpenTimeout:      time.Hour,
+	}
+	done := make(chan error, 3)
+	send := func(name string) {
+		go func() { done <- b.SendVote(Vote{Username: name}) }()
+		<-started
+	}
+
+	// Slow calls start while closed and finish after the breaker opened.
+	slowSuccess, slowFailure := block("success"), block("failure")
+	send("success")
+	send("failure")
+	if err := b.SendVote(Vote{}); err == nil {
+		t.Fatal("expected target error")
+	}
+	slowSuccess <- nil
+	<-done
+	if b.State() != BreakerOpen {
+		t.Fatalf("stale success changed the state to %s", b.State())
+	}
+
+	// A stale failure must not end the half-open probe.
+	b.mu.Lock()
+	b.openedAt = time.Time{}
+	b.mu.Unlock()
+	probe := block("probe")
+	send("probe")
+	slowFailure <- errors.New("target down")
+	<-done
+	if err := b.SendVote(Vote{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected concurrent probe to fail fast, got %v", err)
+	}
+	probe <- nil
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected successful probe to close the breaker, got %s", b.State())
+	}
+}
@@ -5,3 +5,11 @@ type Client interface {
 	// SendVote sends a vote through the client.
 	SendVote(vote Vote) error
 }
+
+// ClientFunc is a function that implements Client.
+type ClientFunc func(vote Vote) error
+
+// SendVote implements Client.
+func (f ClientFunc) SendVote(vote Vote) error {
+	return f(vote)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.minekube.com/votifier"
+)
+
+func runKeygen(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	dir := fs.String("dir", "rsa", "directory to write public.key and private.key to")
+	bits := fs.Int("bits", 2048, "RSA key size in bits")
+	force := fs.Bool("force", false, "overwrite existing keys")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *bits < 1024 {
+		return usagef("-bits must be at least 1024")
+	}
+	if !*force {
+		if _, err := os.Stat(filepath.Join(*dir, votifier.PrivateKeyFile)); err == nil {
+			return fmt.Errorf("%s already contains a key, use -force to overwrite it", *dir)
+		}
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, *bits)
+	if err != nil {
+		return err
+	}
+	if err = votifier.SaveKeyPair(*dir, key); err != nil {
+		return err
+	}
+	pub, err := votifier.EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, pub)
+	return err
+}
+
+func runToken(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	n := fs.Int("n", 1, "number of tokens to generate")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *n < 1 {
+		return usagef("-n must be positive")
+	}
+	for i := 0; i < *n; i++ {
+		token, err := votifier.NewToken()
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintln(stdout, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPublicKey reads a base64 encoded public key from a file,
+// or parses the value itself if it is not a file.
+func loadPublicKey(value string) (*rsa.PublicKey, error) {
+	if data, err := os.ReadFile(value); err == nil {
+		value = string(data)
+	}
+	return votifier.ParsePublicKey(value)
+}
@@ -0,0 +1,123 @@
+// Command votifier sends, receives and inspects Votifier votes.
+//
+// Usage:
+//
+//	votifier <command> [flags]
+//
+// The commands are:
+//
+//	serve   run a Votifier server from a config file
+//	send    send a vote to a server
+//	keygen  generate a NuVotifier compatible RSA key pair
+//	token   generate random v2 tokens
+//	probe   connect to a server and print its greeting
+//
+// Exit codes are 0 on success, 1 if the command failed and 2 on invalid usage.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(fs *flag.FlagSet, args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"serve", "run a Votifier server from a config file", runServe},
+	{"send", "send a vote to a server", runSend},
+	{"keygen", "generate a NuVotifier compatible RSA key pair", runKeygen},
+	{"token", "generate random v2 tokens", runToken},
+	{"probe", "connect to a server and print its greeting", runProbe},
+}
+
+// usageError is returned by commands for invalid flags or arguments.
+type usageError struct{ error }
+
+// flagError is returned by parse for flags the flag package rejected. The
+// flag package already printed the error and usage.
+type flagError struct{ error }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		printUsage(stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet("votifier "+c.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		err := c.run(fs, args[1:], stdout)
+		var usageErr usageError
+		var flagErr flagError
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &flagErr):
+			return exitUsage
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(stderr, "votifier %s: %v\n", c.name, err)
+			fs.Usage()
+			return exitUsage
+		default:
+			fmt.Fprintf(stderr, "votifier %s: %v\n", c.name, err)
+			return exitFailure
+		}
+	}
+	fmt.Fprintf(stderr, "votifier: unknown command %q\n", args[0])
+	printUsage(stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: votifier <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "votifier <command> -h" for the flags of a command.`)
+}
+
+// parse parses flags, turning flag errors into usage errors.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return flagError{err}
+	}
+	if fs.NArg() != 0 {
+		return usagef("unexpected arguments %q", fs.Args())
+	}
+	return nil
+}
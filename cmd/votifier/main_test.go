@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.minekube.com/votifier"
+)
+
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"unknown"}, exitUsage},
+		{[]string{"token", "-n", "0"}, exitUsage},
+		{[]string{"token", "-bogus"}, exitUsage},
+		{[]string{"send", "-user", "golang"}, exitUsage},
+		{[]string{"probe", "-h"}, exitOK},
+	}
+	for _, tt := range tests {
+		if code, _, stderr := runCLI(tt.args...); code != tt.code {
+			t.Errorf("%q: expected exit code %d, got %d: %s", tt.args, tt.code, code, stderr)
+		}
+	}
+}
+
+func TestUsagePrintedOnce(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"send", "-bogus"}, "flag provided but not defined: -bogus"},
+		{[]string{"token", "-n", "0"}, "-n must be positive"},
+	}
+	for _, tt := range tests {
+		_, _, stderr := runCLI(tt.args...)
+		if strings.Count(stderr, tt.err) != 1 || strings.Count(stderr, "Usage of votifier") != 1 {
+			t.Errorf("%q: expected error and usage once, got:\n%s", tt.args, stderr)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	code, stdout, _ := runCLI("token", "-n", "3")
+	if code != exitOK || len(strings.Fields(stdout)) != 3 {
+		t.Errorf("unexpected output %d %q", code, stdout)
+	}
+}
+
+func TestKeygenSendProbe(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "rsa")
+	code, pub, stderr := runCLI("keygen", "-dir", dir)
+	if code != exitOK {
+		t.Fatalf("keygen failed: %s", stderr)
+	}
+	if code, _, _ = runCLI("keygen", "-dir", dir); code != exitFailure {
+		t.Errorf("expected keygen to refuse overwriting keys, got %d", code)
+	}
+	key, err := votifier.LoadKeyPair(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 3)
+	server := &votifier.Server{
+		VoteHandler: func(v *votifier.Vote, p votifier.Protocol) error {
+			received <- v.Username
+			return nil
+		},
+		Records: []votifier.ReceiverRecord{{PrivateKey: key, TokenProvider: votifier.StaticTokenProvider("abcxyz")}},
+	}
+	go server.Serve(ln) //nolint:errcheck
+	addr := ln.Addr().String()
+
+	code, stdout, stderr := runCLI("probe", "-address", addr)
+	if code != exitOK || !strings.Contains(stdout, "version: 2") {
+		t.Errorf("unexpected probe output %d %q %s", code, stdout, stderr)
+	}
+
+	voteFile := filepath.Join(t.TempDir(), "vote.json")
+	if err = os.WriteFile(voteFile, []byte(`{"serviceName":"site","username":"from-file"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sends := [][]string{
+		{"send", "-address", addr, "-key", filepath.Join(dir, votifier.PublicKeyFile), "-user", "v1-user"},
+		{"send", "-address", addr, "-token", "abcxyz", "-user", "v2-user"},
+		{"send", "-address", addr, "-key", strings.TrimSpace(pub), "-token", "abcxyz", "-vote", voteFile},
+	}
+	for _, args := range sends {
+		if code, stdout, stderr = runCLI(args...); code != exitOK {
+			t.Fatalf("%q failed with %d: %s", args, code, stderr)
+		}
+		if got := <-received; !strings.Contains(stdout, got) {
+			t.Errorf("%q: server received %q, output %q", args, got, stdout)
+		}
+	}
+
+	if code, _, _ = runCLI("send", "-address", addr, "-token", "wrong", "-user", "golang"); code != exitFailure {
+		t.Errorf("expected failure with wrong token, got %d", code)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go.minekube.com/votifier"
+)
+
+func runSend(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	address := fs.String("address", "localhost:8192", "host and port of the server")
+	protocol := fs.String("protocol", "auto", "protocol to use: v1, v2 or auto")
+	keyFlag := fs.String("key", "", "v1 public key in base64, or a file containing it")
+	token := fs.String("token", "", "v2 token")
+	voteFile := fs.String("vote", "", `JSON file with the vote to send, "-" for stdin (overrides the vote flags)`)
+	vote := votifier.Vote{}
+	fs.StringVar(&vote.ServiceName, "service", "votifier-cli", "service name of the vote")
+	fs.StringVar(&vote.Username, "user", "", "username of the vote")
+	fs.StringVar(&vote.Address, "user-address", "127.0.0.1", "address of the voting user")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if *voteFile != "" {
+		var err error
+		if vote, err = readVote(*voteFile); err != nil {
+			return err
+		}
+	}
+	if vote.Username == "" {
+		return usagef("a username is required, use -user or -vote")
+	}
+
+	var key *rsa.PublicKey
+	if *keyFlag != "" {
+		var err error
+		if key, err = loadPublicKey(*keyFlag); err != nil {
+			return usagef("invalid -key: %v", err)
+		}
+	}
+
+	if *protocol == "auto" {
+		switch {
+		case key == nil && *token == "":
+			return usagef("-key or -token is required")
+		case key == nil:
+			*protocol = "v2"
+		case *token == "":
+			*protocol = "v1"
+		default:
+			// Prefer v2 if the server supports it.
+			g, err := votifier.Probe(*address)
+			if err != nil {
+				return err
+			}
+			*protocol = "v1"
+			if g.Challenge != "" {
+				*protocol = "v2"
+			}
+		}
+	}
+
+	var client votifier.Client
+	switch *protocol {
+	case "v1":
+		if key == nil {
+			return usagef("-key is required for v1")
+		}
+		client = votifier.NewV1Client(*address, key)
+	case "v2":
+		if *token == "" {
+			return usagef("-token is required for v2")
+		}
+		client = votifier.NewV2Client(*address, *token)
+	default:
+		return usagef("unknown protocol %q", *protocol)
+	}
+
+	if err := client.SendVote(vote); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(stdout, "sent %s vote for %s from %s\n", *protocol, vote.Username, vote.ServiceName)
+	return err
+}
+
+func readVote(name string) (votifier.Vote, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return votifier.Vote{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var vote votifier.Vote
+	if err := json.NewDecoder(r).Decode(&vote); err != nil {
+		return votifier.Vote{}, fmt.Errorf("error decoding vote: %w", err)
+	}
+	return vote, nil
+}
+
+func runProbe(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	address := fs.String("address", "localhost:8192", "host and port of the server")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	g, err := votifier.Probe(*address)
+	if err != nil {
+		return err
+	}
+	protocols := "v1"
+	if g.Challenge != "" {
+		protocols = "v1, v2"
+	}
+	_, err = fmt.Fprintf(stdout, "version: %s\nprotocols: %s\n", g.Version, protocols)
+	return err
+}
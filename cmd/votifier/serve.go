@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/config"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+func runServe(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	configFile := fs.String("config", "votifier.yml", "YAML, TOML or JSON config file")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		return err
+	}
+	log.SetOutput(stdout)
+	server, closeHandlers, err := config.NewServerFromConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *configFile, err)
+	}
+	defer func() {
+		if err := closeHandlers(); err != nil {
+			log.Printf("error closing handlers: %v", err)
+		}
+	}()
+	server.OnErr = func(c net.Conn, err error) {
+		log.Printf("error handling %s: %v", c.RemoteAddr(), err)
+	}
+
+	if err = cfg.Listen(server); err != nil {
+		return fmt.Errorf("%s: %w", *configFile, err)
+	}
+	for _, name := range server.Listeners() {
+		log.Printf("listening on %s", name)
+	}
+	return serveUntilSignal(server)
+}
+
+// serveUntilSignal serves until SIGINT or SIGTERM is received, then waits
+// up to shutdownTimeout for votes being handled.
+func serveUntilSignal(server *votifier.Server) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("error shutting down: %v", err)
+		}
+	}()
+
+	if err := server.ServeAll(); !errors.Is(err, votifier.ErrServerClosed) {
+		return err
+	}
+	<-shutdown
+	return nil
+}
@@ -0,0 +1,378 @@
+// Package config loads a Votifier server from a declarative YAML, TOML or
+// JSON file:
+//
+//	listeners:
+//	  - address: "0.0.0.0:8192"
+//	  - name: ipv6
+//	    address: "[::]:8192"
+//	  - name: sidecar
+//	    network: unix
+//	    address: /run/votifier/votifier.sock
+//	    mode: "0660"
+//	keys:
+//	  dir: rsa
+//	  generate: true
+//	  previous:
+//	    - dir: rsa-2025
+//	      expires: 2026-12-01T00:00:00Z
+//	secrets:
+//	  vault:
+//	    address: https://vault.example.org:8200
+//	tokens:
+//	  default: secret:env:VOTIFIER_TOKEN
+//	  example.org: secret:vault:votifier/tokens#example.org
+//	previousTokens:
+//	  - service: example.org
+//	    token: 5b0gq8k1ojfd6c3u0ll2s7p9ah
+//	    expires: 2026-12-01T00:00:00Z
+//	protocols: [v1, v2]
+//	timeout: 5s
+//	rateLimit:
+//	  perMinute: 60
+//	  burst: 10
+//	handlers:
+//	  - type: log
+//	  - type: file
+//	    path: votes.jsonl
+//	  - type: webhook
+//	    url: https://discord.com/api/webhooks/...
+//	    template: discord
+//	  - type: exec
+//	    command: [./reward.sh, "{username}", "{service}"]
+//	    timeout: 3s
+//
+// Tokens, private keys and webhook secrets can be read from a file, an
+// environment variable or Vault instead of the config file with a secret
+// reference, see SecretPrefix. References are resolved when the server is
+// created.
+//
+// Handlers are run in order for every accepted vote; a failing handler
+// rejects the vote and skips the remaining handlers.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.minekube.com/votifier/internal/decode"
+)
+
+// DefaultAddress is listened on if no listeners are configured and the
+// process was not started by systemd socket activation.
+const DefaultAddress = ":8192"
+
+// Handler types.
+const (
+	Log     = "log"     // Logs votes with the standard logger.
+	File    = "file"    // Appends votes to a store.Log file.
+	Webhook = "webhook" // Posts votes to a URL, see the webhook package.
+	Exec    = "exec"    // Runs a command with placeholders such as {username} in its arguments.
+)
+
+// Config describes a Votifier server.
+type Config struct {
+	Listeners      []Listener        `json:"listeners"`      // Defaults to the systemd sockets or DefaultAddress.
+	Keys           *Keys             `json:"keys"`           // RSA key pair of the v1 protocol.
+	Tokens         map[string]string `json:"tokens"`         // v2 tokens per service, "default" applies to all others.
+	PreviousTokens []PreviousToken   `json:"previousTokens"` // Rotated v2 tokens still accepted.
+	Protocols      []string          `json:"protocols"`      // "v1" and/or "v2", defaults to those configured.
+	Timeout        Duration          `json:"timeout"`        // Deadline of a connection, defaults to 5 seconds.
+	RateLimit      *RateLimit        `json:"rateLimit"`      // Optional limit of connections per client IP.
+	Handlers       []Handler         `json:"handlers"`
+	Secrets        *Secrets          `json:"secrets"` // Sources of secret references.
+}
+
+// PreviousToken is a rotated v2 token of a service that is accepted until it expires.
+type PreviousToken struct {
+	Service string    `json:"service"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Listener networks.
+const (
+	TCP     = "tcp"
+	Unix    = "unix"
+	Systemd = "systemd" // Sockets passed by systemd socket activation.
+)
+
+// Listener is an address the server listens on.
+type Listener struct {
+	Name    string `json:"name"`    // Reported in the VoteInfo of votes, defaults to the address.
+	Network string `json:"network"` // tcp, unix or systemd, defaults to tcp.
+	// The host and port for tcp, the socket path for unix and the
+	// FileDescriptorName= of the sockets for systemd, or all sockets if empty.
+	Address string `json:"address"`
+	Mode    string `json:"mode"` // unix: optional octal permissions of the socket, e.g. "0660".
+}
+
+// Keys locates the RSA key pair in NuVotifier's format.
+type Keys struct {
+	Dir        string        `json:"dir"`        // Directory containing public.key and private.key.
+	PrivateKey string        `json:"privateKey"` // Alternative to Dir, the content of private.key or a secret reference.
+	Generate   bool          `json:"generate"`   // Generate a key pair if the directory has none.
+	Previous   []PreviousKey `json:"previous"`   // Rotated keys still accepted, see votifier.KeyRing.
+}
+
+// PreviousKey is a rotated key pair that decrypts votes until it expires.
+type PreviousKey struct {
+	Dir        string    `json:"dir"`
+	PrivateKey string    `json:"privateKey"` // Alternative to Dir.
+	Expires    time.Time `json:"expires"`
+}
+
+// RateLimit limits the connections of a client IP with a token bucket.
+type RateLimit struct {
+	PerMinute int `json:"perMinute"` // Sustained connections per minute.
+	Burst     int `json:"burst"`     // Connections allowed at once, defaults to PerMinute.
+}
+
+// Handler is a sink of accepted votes.
+type Handler struct {
+	Type string `json:"type"`
+
+	Path string `json:"path"` // file: the file votes are appended to.
+
+	URL      string            `json:"url"`      // webhook: the URL votes are posted to.
+	Secret   string            `json:"secret"`   // webhook: optional, signs request bodies. May be a secret reference.
+	Template string            `json:"template"` // webhook: optional body template, or "discord".
+	Headers  map[string]string `json:"headers"`  // webhook: optional additional request headers.
+
+	Command []string `json:"command"` // exec: the program and its arguments.
+	// exec: defaults to half the connection timeout and must be shorter, as
+	// the command runs before the vote site gets its answer.
+	// webhook: defaults to 10 seconds per request.
+	Timeout Duration `json:"timeout"`
+}
+
+// Duration is a time.Duration written as a string such as "5s" or "1m30s".
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New(`duration must be a string such as "5s"`)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads a config from a YAML, TOML or JSON file, by its extension.
+func Load(path string) (*Config, error) {
+	var cfg Config
+	if err := decode.File(path, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Parse parses and validates a config in the given format ("yaml", "toml" or "json").
+func Parse(data []byte, format string) (*Config, error) {
+	var cfg Config
+	if err := decode.Unmarshal(data, format, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the config. Errors name the offending field, e.g.
+// "handlers[1].url: required".
+func (c *Config) Validate() error {
+	for i := range c.Listeners {
+		if err := c.Listeners[i].validate(); err != nil {
+			return fmt.Errorf("listeners[%d].%w", i, err)
+		}
+	}
+	if c.Secrets != nil {
+		if err := c.Secrets.validate(); err != nil {
+			return fmt.Errorf("secrets.%w", err)
+		}
+	}
+	if c.Keys != nil {
+		if (c.Keys.Dir == "") == (c.Keys.PrivateKey == "") {
+			return errors.New("keys.dir: either dir or privateKey is required")
+		}
+		if c.Keys.PrivateKey != "" && c.Keys.Generate {
+			return errors.New("keys.generate: only supported with dir")
+		}
+		if err := c.validateRef(c.Keys.PrivateKey); err != nil {
+			return fmt.Errorf("keys.privateKey: %w", err)
+		}
+		for i, p := range c.Keys.Previous {
+			if (p.Dir == "") == (p.PrivateKey == "") {
+				return fmt.Errorf("keys.previous[%d].dir: either dir or privateKey is required", i)
+			}
+			if err := c.validateRef(p.PrivateKey); err != nil {
+				return fmt.Errorf("keys.previous[%d].privateKey: %w", i, err)
+			}
+			if p.Expires.IsZero() {
+				return fmt.Errorf("keys.previous[%d].expires: required", i)
+			}
+		}
+	}
+	for service, token := range c.Tokens {
+		if token == "" {
+			return fmt.Errorf("tokens.%s: must not be empty", service)
+		}
+		if err := c.validateRef(token); err != nil {
+			return fmt.Errorf("tokens.%s: %w", service, err)
+		}
+	}
+	for i, p := range c.PreviousTokens {
+		if _, ok := c.Tokens[p.Service]; !ok {
+			return fmt.Errorf("previousTokens[%d].service: %q has no current token in tokens", i, p.Service)
+		}
+		if p.Token == "" {
+			return fmt.Errorf("previousTokens[%d].token: required", i)
+		}
+		if err := c.validateRef(p.Token); err != nil {
+			return fmt.Errorf("previousTokens[%d].token: %w", i, err)
+		}
+		if p.Expires.IsZero() {
+			return fmt.Errorf("previousTokens[%d].expires: required", i)
+		}
+	}
+	for i, p := range c.Protocols {
+		switch strings.ToLower(p) {
+		case "v1":
+			if c.Keys == nil {
+				return fmt.Errorf("protocols[%d]: v1 requires keys", i)
+			}
+		case "v2":
+			if len(c.Tokens) == 0 {
+				return fmt.Errorf("protocols[%d]: v2 requires tokens", i)
+			}
+		default:
+			return fmt.Errorf("protocols[%d]: unknown protocol %q, expected v1 or v2", i, p)
+		}
+	}
+	if c.Keys == nil && len(c.Tokens) == 0 {
+		return errors.New("keys or tokens are required")
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout: must not be negative")
+	}
+	if r := c.RateLimit; r != nil {
+		if r.PerMinute <= 0 {
+			return errors.New("rateLimit.perMinute: must be positive")
+		}
+		if r.Burst < 0 {
+			return errors.New("rateLimit.burst: must not be negative")
+		}
+	}
+	for i := range c.Handlers {
+		if err := c.Handlers[i].validate(); err != nil {
+			return fmt.Errorf("handlers[%d].%w", i, err)
+		}
+		if h := &c.Handlers[i]; h.Type == Exec && time.Duration(h.Timeout) >= c.connTimeout() {
+			return fmt.Errorf("handlers[%d].timeout: must be shorter than the connection timeout of %s", i, c.connTimeout())
+		}
+		if err := c.validateRef(c.Handlers[i].Secret); err != nil {
+			return fmt.Errorf("handlers[%d].secret: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// connTimeout returns the deadline of a connection.
+func (c *Config) connTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.Timeout)
+}
+
+func (l *Listener) validate() error {
+	switch l.Network {
+	case "", TCP, Unix:
+		if l.Address == "" {
+			return errors.New("address: required")
+		}
+	case Systemd:
+	default:
+		return fmt.Errorf("network: unknown network %q, expected tcp, unix or systemd", l.Network)
+	}
+	if l.Mode != "" {
+		if l.Network != Unix {
+			return errors.New("mode: only supported by unix listeners")
+		}
+		if _, err := l.perm(); err != nil {
+			return fmt.Errorf("mode: %q is not an octal file mode", l.Mode)
+		}
+	}
+	return nil
+}
+
+func (l *Listener) perm() (os.FileMode, error) {
+	if l.Mode == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(l.Mode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, errors.New("invalid mode")
+	}
+	return os.FileMode(mode), nil
+}
+
+func (h *Handler) validate() error {
+	if h.Timeout < 0 {
+		return errors.New("timeout: must not be negative")
+	}
+	switch h.Type {
+	case Log:
+	case File:
+		if h.Path == "" {
+			return errors.New("path: required for file handlers")
+		}
+	case Webhook:
+		if h.URL == "" {
+			return errors.New("url: required for webhook handlers")
+		}
+		if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+			return fmt.Errorf("url: %q is not an http(s) URL", h.URL)
+		}
+	case Exec:
+		if len(h.Command) == 0 || h.Command[0] == "" {
+			return errors.New("command: required for exec handlers")
+		}
+	case "":
+		return errors.New("type: required")
+	default:
+		return fmt.Errorf("type: unknown handler type %q, expected log, file, webhook or exec", h.Type)
+	}
+	return nil
+}
+
+// enabled reports whether protocol p ("v1" or "v2") is enabled.
+func (c *Config) enabled(p string) bool {
+	if len(c.Protocols) == 0 {
+		return true
+	}
+	for _, q := range c.Protocols {
+		if strings.EqualFold(q, p) {
+			return true
+		}
+	}
+	return false
+}
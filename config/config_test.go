@@ -0,0 +1,478 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/store"
+)
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{"yaml", `
+listeners:
+  - address: "127.0.0.1:0"
+tokens:
+  default: abc
+timeout: 3s
+rateLimit: {perMinute: 60}
+handlers:
+  - type: log
+`},
+		{"toml", `
+timeout = "3s"
+
+[[listeners]]
+address = "127.0.0.1:0"
+
+[tokens]
+default = "abc"
+
+[rateLimit]
+perMinute = 60
+
+[[handlers]]
+type = "log"
+`},
+		{"json", `{
+	"listeners": [{"address": "127.0.0.1:0"}],
+	"tokens": {"default": "abc"},
+	"timeout": "3s",
+	"rateLimit": {"perMinute": 60},
+	"handlers": [{"type": "log"}]
+}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.Listeners) != 1 || cfg.Listeners[0].Address != "127.0.0.1:0" {
+				t.Errorf("unexpected listeners %+v", cfg.Listeners)
+			}
+			if cfg.Tokens["default"] != "abc" {
+				t.Errorf("unexpected tokens %v", cfg.Tokens)
+			}
+			if time.Duration(cfg.Timeout) != 3*time.Second {
+				t.Errorf("unexpected timeout %v", time.Duration(cfg.Timeout))
+			}
+			if cfg.RateLimit == nil || cfg.RateLimit.PerMinute != 60 {
+				t.Errorf("unexpected rate limit %+v", cfg.RateLimit)
+			}
+			if len(cfg.Handlers) != 1 || cfg.Handlers[0].Type != Log {
+				t.Errorf("unexpected handlers %+v", cfg.Handlers)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		data    string
+		wantErr string
+	}{
+		{"handlers: [{type: log}]", "keys or tokens are required"},
+		{"tokens: {default: abc}\nlisteners: [{}]", "listeners[0].address: required"},
+		{"tokens: {default: abc}\nlisteners: [{network: udp, address: x}]", `listeners[0].network: unknown network "udp"`},
+		{"tokens: {default: abc}\nlisteners: [{address: x, mode: '0660'}]", "listeners[0].mode: only supported by unix listeners"},
+		{"tokens: {default: abc}\nlisteners: [{network: unix, address: x, mode: rw}]", `listeners[0].mode: "rw" is not an octal file mode`},
+		{"keys: {generate: true}", "keys.dir: either dir or privateKey is required"},
+		{"keys: {privateKey: 'secret:env:KEY', generate: true}", "keys.generate: only supported with dir"},
+		{"tokens: {site: 'secret:vault:votifier#site'}", "tokens.site: vault secrets are not configured"},
+		{"tokens: {site: 'secret:s3:x'}", `tokens.site: unknown secret source "s3"`},
+		{"tokens: {site: 'secret:env:'}", "tokens.site: secret reference \"secret:env:\" has no name"},
+		{"tokens: {site: abc}\nsecrets: {vault: {mount: kv}}", "secrets.vault.address: required"},
+		{"tokens: {site: abc}\npreviousTokens: [{service: other, token: x}]", `previousTokens[0].service: "other" has no current token`},
+		{"tokens: {site: abc}\npreviousTokens: [{service: site, token: x}]", "previousTokens[0].expires: required"},
+		{"keys: {dir: rsa, previous: [{dir: old}]}", "keys.previous[0].expires: required"},
+		{"tokens: {default: ''}", "tokens.default: must not be empty"},
+		{"tokens: {default: abc}\nprotocols: [v2, v1]", "protocols[1]: v1 requires keys"},
+		{"tokens: {default: abc}\nprotocols: [v3]", `protocols[0]: unknown protocol "v3"`},
+		{"tokens: {default: abc}\ntimeout: 5", "duration must be a string"},
+		{"tokens: {default: abc}\ntimeout: 5x", "unknown unit"},
+		{"tokens: {default: abc}\nrateLimit: {burst: 5}", "rateLimit.perMinute: must be positive"},
+		{"tokens: {default: abc}\nhandlers: [{type: log}, {path: x}]", "handlers[1].type: required"},
+		{"tokens: {default: abc}\nhandlers: [{type: mail}]", `handlers[0].type: unknown handler type "mail"`},
+		{"tokens: {default: abc}\nhandlers: [{type: file}]", "handlers[0].path: required"},
+		{"tokens: {default: abc}\nhandlers: [{type: webhook, url: 'ftp://x'}]", "handlers[0].url: \"ftp://x\" is not an http(s) URL"},
+		{"tokens: {default: abc}\nhandlers: [{type: exec, command: []}]", "handlers[0].command: required"},
+		{"tokens: {default: abc}\nhandlers: [{type: exec, command: [x], timeout: 10s}]", "handlers[0].timeout: must be shorter than the connection timeout of 5s"},
+		{"tokens: {default: abc}\ntimeout: 2s\nhandlers: [{type: exec, command: [x], timeout: 3s}]", "handlers[0].timeout: must be shorter than the connection timeout of 2s"},
+		{"tokens: {default: abc}\nlistener: []", `unknown field "listener"`},
+		{"tokens: {default: abc}\nhandlers: [{type: log}, {type: webhook, urll: x}]", `handlers[1]: unknown field "urll"`},
+		{"tokens: {default: abc}\nhandlers: [{type: exec, command: [x], timeout: 5}]", `handlers[0].timeout: duration must be a string`},
+		{"tokens: {default: abc}\nrateLimit: {perMinute: many}", "rateLimit.perMinute: expected an integer, got a string"},
+		{"tokens: {default: [abc]}", "tokens.default: expected a string, got a list"},
+		{"keys: {dir: rsa, previous: [{dir: old, expires: tomorrow}]}", "keys.previous[0].expires: parsing time"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.data), "yaml")
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.data, tt.wantErr, err)
+		}
+	}
+}
+
+func TestLoadPrefixesPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "votifier.toml")
+	if err := os.WriteFile(path, []byte("[[handlers]]\ntype = \"log\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	if err == nil || !strings.HasPrefix(err.Error(), path+": ") {
+		t.Errorf("expected error prefixed with the path, got %v", err)
+	}
+}
+
+func TestNewServerFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	posted := make(chan struct{}, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted <- struct{}{}
+	}))
+	defer hook.Close()
+
+	cfg := &Config{
+		Listeners: []Listener{{Address: "127.0.0.1:0"}},
+		Keys:      &Keys{Dir: filepath.Join(dir, "rsa"), Generate: true},
+		Tokens:    map[string]string{"default": "abc"},
+		Handlers: []Handler{
+			{Type: File, Path: filepath.Join(dir, "votes.jsonl")},
+			{Type: Webhook, URL: hook.URL},
+			{Type: Exec, Command: []string{"sh", "-c", `echo "$1 $VOTIFIER_SERVICE" >> "$2"`, "sh", "{username}", filepath.Join(dir, "exec.txt")}},
+		},
+	}
+	server, closeHandlers, err := NewServerFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := votifier.LoadKeyPair(cfg.Keys.Dir)
+	if err != nil {
+		t.Fatalf("key pair was not generated: %v", err)
+	}
+
+	if err = cfg.Listen(server); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go server.ServeAll() //nolint:errcheck
+	address := server.Listeners()[0]
+
+	clients := []votifier.Client{
+		votifier.NewV1Client(address, &key.PublicKey),
+		votifier.NewV2Client(address, "abc"),
+	}
+	for _, c := range clients {
+		if err = c.SendVote(votifier.Vote{ServiceName: "example.org", Username: "Notch", Address: "127.0.0.1"}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-posted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook was not called")
+		}
+	}
+
+	out, err := os.ReadFile(filepath.Join(dir, "exec.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); got != "Notch example.org\nNotch example.org\n" {
+		t.Errorf("unexpected command output %q", got)
+	}
+	if err = closeHandlers(); err != nil {
+		t.Fatal(err)
+	}
+	log, err := store.Open(filepath.Join(dir, "votes.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	records, err := log.Query(store.Query{Username: "Notch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Protocol != votifier.V1 || records[1].Protocol != votifier.V2 {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+func TestProtocolsDisableV1(t *testing.T) {
+	cfg := &Config{
+		Keys:      &Keys{Dir: filepath.Join(t.TempDir(), "missing")},
+		Tokens:    map[string]string{"default": "abc"},
+		Protocols: []string{"v2"},
+	}
+	server, _, err := NewServerFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("keys of a disabled protocol should not be loaded: %v", err)
+	}
+	if server.Records[0].ActiveKey() != nil {
+		t.Error("expected v1 to be disabled")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	r := newRateLimiter(60, 2)
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if got := r.allow("10.0.0.1", now); got != want {
+			t.Errorf("connection %d: allowed %v, want %v", i, got, want)
+		}
+	}
+	if !r.allow("10.0.0.2", now) {
+		t.Error("other clients should not be limited")
+	}
+	if !r.allow("10.0.0.1", now.Add(time.Second)) {
+		t.Error("expected a token to be refilled after a second")
+	}
+	if r.allow("10.0.0.1", now.Add(time.Second)) {
+		t.Error("expected only one token to be refilled")
+	}
+
+	r.allow("10.0.0.3", now.Add(2*time.Minute))
+	if _, ok := r.buckets["10.0.0.2"]; ok {
+		t.Error("expected refilled buckets to be pruned")
+	}
+}
+
+func TestRateLimitedServer(t *testing.T) {
+	server, _, err := NewServerFromConfig(&Config{
+		Tokens:    map[string]string{"default": "abc"},
+		RateLimit: &RateLimit{PerMinute: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go server.Serve(ln) //nolint:errcheck
+
+	client := votifier.NewV2Client(ln.Addr().String(), "abc")
+	vote := votifier.Vote{ServiceName: "example.org", Username: "Notch"}
+	if err = client.SendVote(vote); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.SendVote(vote); err == nil {
+		t.Error("expected the second vote to be rate limited")
+	}
+	if s := server.Stats(); s.Votes != 1 || s.Errors != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "votifier.sock")
+	cfg, err := Parse([]byte(`
+tokens: {default: abc}
+listeners:
+  - name: sidecar
+    network: unix
+    address: `+path+`
+    mode: "0600"
+  - address: 127.0.0.1:0
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _, err := NewServerFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cfg.Listen(server); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go server.ServeAll() //nolint:errcheck
+
+	names := server.Listeners()
+	if len(names) != 2 || names[0] != "sidecar" || !strings.HasPrefix(names[1], "127.0.0.1:") {
+		t.Errorf("unexpected listeners %q", names)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected socket with permissions 0600, got %v, %v", fi, err)
+	}
+	client := votifier.NewV2Client("unix:"+path, "abc")
+	if err = client.SendVote(votifier.Vote{ServiceName: "example.org", Username: "Notch"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPreviousKeys(t *testing.T) {
+	dir := t.TempDir()
+	var keys []*rsa.PrivateKey
+	for _, name := range []string{"rsa", "rsa-old"} {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = votifier.SaveKeyPair(filepath.Join(dir, name), key); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	cfg, err := Parse([]byte(`
+keys:
+  dir: `+filepath.Join(dir, "rsa")+`
+  previous:
+    - dir: `+filepath.Join(dir, "rsa-old")+`
+      expires: 2999-01-01T00:00:00Z
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _, err := NewServerFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := server.Records[0].KeyRing
+	if _, active := ring.Active(); !active.Equal(keys[0]) {
+		t.Error("expected the key of dir to be active")
+	}
+	infos := ring.Keys()
+	if len(infos) != 2 || infos[1].ID != votifier.KeyID(&keys[1].PublicKey) || infos[1].Expires.Year() != 2999 {
+		t.Errorf("unexpected keys %+v", infos)
+	}
+}
+
+func TestPreviousTokens(t *testing.T) {
+	cfg, err := Parse([]byte(`
+tokens: {site: new}
+previousTokens:
+  - service: site
+    token: old
+    expires: 2999-01-01T00:00:00Z
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _, err := NewServerFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := votifier.TokensOf(server.Records[0].TokenProvider, "site"); strings.Join(got, ",") != "new,old" {
+		t.Errorf("expected the current and previous token, got %q", got)
+	}
+}
+
+func TestSecretReferences(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := votifier.EncodePrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/data/votifier" || r.Header.Get("X-Vault-Token") != "vault-token" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"site":"vault-site-token","rsa":"` + encoded + `"}}}`))
+	}))
+	defer vault.Close()
+	dir := t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, "old-token"), []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VOTIFIER_DEFAULT", "env-token")
+	t.Setenv("MY_VAULT_TOKEN", "vault-token")
+
+	cfg, err := Parse([]byte(`
+secrets:
+  dir: `+dir+`
+  envPrefix: VOTIFIER_
+  vault:
+    address: `+vault.URL+`
+    mount: kv
+    tokenEnv: MY_VAULT_TOKEN
+keys:
+  privateKey: secret:vault:votifier#rsa
+tokens:
+  default: secret:env:DEFAULT
+  site: secret:vault:votifier#site
+previousTokens:
+  - service: site
+    token: secret:file:old-token
+    expires: 2999-01-01T00:00:00Z
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _, err := NewServerFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := server.Records[0]
+	if !record.ActiveKey().Equal(key) {
+		t.Error("expected the key from vault")
+	}
+	if got := strings.Join(votifier.TokensOf(record.TokenProvider, "site"), ","); got != "vault-site-token,file-token" {
+		t.Errorf("unexpected site tokens %q", got)
+	}
+	if got := record.TokenProvider.Token("other"); got != "env-token" {
+		t.Errorf("unexpected default token %q", got)
+	}
+
+	t.Setenv("MY_VAULT_TOKEN", "wrong")
+	if _, _, err = NewServerFromConfig(cfg); err == nil || !strings.Contains(err.Error(), "keys:") {
+		t.Errorf("expected an error naming the field, got %v", err)
+	}
+}
+
+func TestEmptySecretToken(t *testing.T) {
+	t.Setenv("EMPTY_TOKEN", "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cfg     *Config
+		wantErr string
+	}{
+		{&Config{Tokens: map[string]string{"site": "secret:env:EMPTY_TOKEN"}}, "tokens.site: secret is empty"},
+		{&Config{
+			Tokens:  map[string]string{"site": "abc"},
+			Secrets: &Secrets{Dir: dir},
+			PreviousTokens: []PreviousToken{
+				{Service: "site", Token: "secret:file:token", Expires: time.Now().Add(time.Hour)},
+			},
+		}, "previousTokens[0]: secret is empty"},
+	}
+	for _, tt := range tests {
+		if _, _, err := NewServerFromConfig(tt.cfg); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("expected error %q, got %v", tt.wantErr, err)
+		}
+	}
+}
+
+func TestRunCommandRejectsInvalidUsername(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+	command := []string{"sh", "-c", `echo "give $1" >> "$2"`, "sh", "{username}", out}
+	for _, username := range []string{"@a", "Notch @a", "Notch;id", ""} {
+		err := runCommand(command, &votifier.Vote{Username: username}, time.Second)
+		if err == nil || !strings.Contains(err.Error(), "invalid Minecraft username") {
+			t.Errorf("%q: expected invalid username error, got %v", username, err)
+		}
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("expected command not to run, got %v", err)
+	}
+}
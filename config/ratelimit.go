@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket per client IP.
+type rateLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+	}
+}
+
+// accept rejects connections of clients that exceeded the limit.
+func (r *rateLimiter) accept(c net.Conn) error {
+	host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+	if err != nil {
+		// Not an IP connection, such as a Unix socket.
+		return nil
+	}
+	if !r.allow(host, time.Now()) {
+		return fmt.Errorf("rate limit exceeded for %s", host)
+	}
+	return nil
+}
+
+func (r *rateLimiter) allow(host string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(now)
+
+	b, ok := r.buckets[host]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[host] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * r.rate
+	if b.tokens > r.burst {
+		b.tokens = r.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune forgets clients whose buckets have refilled, at most once a minute.
+func (r *rateLimiter) prune(now time.Time) {
+	if now.Sub(r.pruned) < time.Minute {
+		return
+	}
+	r.pruned = now
+	full := time.Duration(r.burst / r.rate * float64(time.Second))
+	for host, b := range r.buckets {
+		if now.Sub(b.last) >= full {
+			delete(r.buckets, host)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/secrets"
+)
+
+// SecretPrefix marks config values that are read from a secret source,
+// e.g. "secret:vault:votifier/tokens#example.org", "secret:env:SITE_TOKEN"
+// or "secret:file:site-token".
+const SecretPrefix = "secret:"
+
+const secretTimeout = 10 * time.Second
+
+// Secrets configures the sources secret references are read from.
+type Secrets struct {
+	Dir       string       `json:"dir"`       // Directory of the file source, defaults to /run/secrets.
+	EnvPrefix string       `json:"envPrefix"` // Prefix of the environment variables of the env source.
+	Vault     *VaultConfig `json:"vault"`     // Enables the vault source.
+}
+
+// VaultConfig configures a HashiCorp Vault KV secrets engine, see secrets.Vault.
+type VaultConfig struct {
+	Address   string `json:"address"`
+	Mount     string `json:"mount"`     // Defaults to "secret".
+	KVVersion int    `json:"kvVersion"` // 1 or 2, defaults to 2.
+	Namespace string `json:"namespace"`
+	TokenEnv  string `json:"tokenEnv"` // Environment variable holding the Vault token, defaults to VAULT_TOKEN.
+}
+
+// secretRef splits a secret reference into its source and name. It reports
+// false if value is not a reference.
+func secretRef(value string) (source, name string, ok bool) {
+	ref := strings.TrimPrefix(value, SecretPrefix)
+	if ref == value {
+		return "", "", false
+	}
+	source, name, _ = strings.Cut(ref, ":")
+	return source, name, true
+}
+
+func (s *Secrets) validate() error {
+	if s.Vault == nil {
+		return nil
+	}
+	if s.Vault.Address == "" {
+		return errors.New("vault.address: required")
+	}
+	if v := s.Vault.KVVersion; v != 0 && v != 1 && v != 2 {
+		return fmt.Errorf("vault.kvVersion: must be 1 or 2, got %d", v)
+	}
+	return nil
+}
+
+// validateRef checks a secret reference in value, if any.
+func (c *Config) validateRef(value string) error {
+	source, name, ok := secretRef(value)
+	if !ok {
+		return nil
+	}
+	if name == "" {
+		return fmt.Errorf("secret reference %q has no name", value)
+	}
+	switch source {
+	case "file", "env":
+	case "vault":
+		if c.Secrets == nil || c.Secrets.Vault == nil {
+			return errors.New("vault secrets are not configured, see secrets.vault")
+		}
+	default:
+		return fmt.Errorf("unknown secret source %q, expected file, env or vault", source)
+	}
+	return nil
+}
+
+// source returns the secret source of a reference.
+func (c *Config) source(name string) (votifier.SecretSource, error) {
+	s := c.Secrets
+	if s == nil {
+		s = &Secrets{}
+	}
+	switch name {
+	case "file":
+		dir := s.Dir
+		if dir == "" {
+			dir = "/run/secrets"
+		}
+		return &secrets.File{Dir: dir}, nil
+	case "env":
+		return &secrets.Env{Prefix: s.EnvPrefix}, nil
+	case "vault":
+		tokenEnv := s.Vault.TokenEnv
+		if tokenEnv == "" {
+			tokenEnv = "VAULT_TOKEN"
+		}
+		token, ok := os.LookupEnv(tokenEnv)
+		if !ok {
+			return nil, fmt.Errorf("vault token environment variable %s is not set", tokenEnv)
+		}
+		return &secrets.Vault{
+			Address:   s.Vault.Address,
+			Token:     token,
+			Mount:     s.Vault.Mount,
+			KVVersion: s.Vault.KVVersion,
+			Namespace: s.Vault.Namespace,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown secret source %q", name)
+}
+
+// resolve returns value, or the secret it references.
+func (c *Config) resolve(value string) (string, error) {
+	source, name, ok := secretRef(value)
+	if !ok {
+		return value, nil
+	}
+	src, err := c.source(source)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	return src.Secret(ctx, name)
+}
+
+// resolveToken resolves a v2 token, which must not be empty: anyone could
+// sign votes with an empty key.
+func (c *Config) resolveToken(value string) (string, error) {
+	token, err := c.resolve(value)
+	if err == nil && token == "" {
+		err = errors.New("secret is empty")
+	}
+	return token, err
+}
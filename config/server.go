@@ -0,0 +1,310 @@
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/internal/placeholder"
+	"go.minekube.com/votifier/store"
+	"go.minekube.com/votifier/webhook"
+)
+
+const defaultHandlerTimeout = 10 * time.Second
+
+// NewServerFromConfig returns a server configured by cfg, loading or
+// generating its keys and opening its handler sinks. Add its listeners
+// with cfg.Listen before serving it.
+//
+// The returned function closes the handler sinks, waiting for webhook
+// deliveries in progress. Call it after the server was shut down.
+func NewServerFromConfig(cfg *Config) (server *votifier.Server, closeHandlers func() error, err error) {
+	if err = cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	var record votifier.ReceiverRecord
+	if cfg.Keys != nil && cfg.enabled("v1") {
+		ring, err := cfg.loadKeyRing()
+		if err != nil {
+			return nil, nil, err
+		}
+		record.KeyRing = ring
+	}
+	if len(cfg.Tokens) != 0 && cfg.enabled("v2") {
+		tokens := votifier.NewMapTokenProvider(nil)
+		for service, token := range cfg.Tokens {
+			token, err := cfg.resolveToken(token)
+			if err != nil {
+				return nil, nil, fmt.Errorf("tokens.%s: %w", service, err)
+			}
+			tokens.Set(service, token)
+		}
+		for i, p := range cfg.PreviousTokens {
+			token, err := cfg.resolveToken(p.Token)
+			if err == nil {
+				err = tokens.AddGraceToken(p.Service, token, p.Expires)
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("previousTokens[%d]: %w", i, err)
+			}
+		}
+		record.TokenProvider = tokens
+	}
+
+	var closers []func() error
+	closeHandlers = func() error {
+		var firstErr error
+		for _, c := range closers {
+			if err := c(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	defer func() {
+		if err != nil {
+			closeHandlers()
+		}
+	}()
+	handlers := make([]votifier.VoteInfoListener, len(cfg.Handlers))
+	for i := range cfg.Handlers {
+		h := cfg.Handlers[i]
+		secret, err := cfg.resolve(h.Secret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("handlers[%d].secret: %w", i, err)
+		}
+		h.Secret = secret
+		handler, closer, err := newHandler(&h, cfg.connTimeout())
+		if err != nil {
+			return nil, nil, fmt.Errorf("handlers[%d]: %w", i, err)
+		}
+		handlers[i] = handler
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+
+	server = &votifier.Server{
+		VoteInfoHandler: func(v *votifier.Vote, info *votifier.VoteInfo) error {
+			for _, h := range handlers {
+				if err := h(v, info); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Records: []votifier.ReceiverRecord{record},
+		Timeout: time.Duration(cfg.Timeout),
+	}
+	if r := cfg.RateLimit; r != nil {
+		server.Accept = newRateLimiter(r.PerMinute, r.Burst).accept
+	}
+	return server, closeHandlers, nil
+}
+
+// Listen listens on the configured addresses and adds the listeners to
+// server. If there are none, the sockets passed by systemd socket
+// activation are used, or DefaultAddress if the process was not socket
+// activated.
+func (c *Config) Listen(server *votifier.Server) error {
+	activated, err := votifier.SystemdListeners()
+	if err != nil {
+		return err
+	}
+	listeners := c.Listeners
+	if len(listeners) == 0 {
+		if len(activated) != 0 {
+			listeners = []Listener{{Network: Systemd}}
+		} else {
+			listeners = []Listener{{Address: DefaultAddress}}
+		}
+	}
+
+	type named struct {
+		name string
+		net.Listener
+	}
+	var lns []named
+	closeAll := func() {
+		for _, ln := range lns {
+			ln.Close()
+		}
+		for _, ln := range activated {
+			ln.Close()
+		}
+	}
+	used := make([]bool, len(activated))
+	for i, l := range listeners {
+		if l.Network == Systemd {
+			n := len(lns)
+			for j, a := range activated {
+				if !used[j] && (l.Address == "" || a.Name == l.Address) {
+					used[j] = true
+					name := a.Name
+					if l.Name != "" {
+						name = l.Name
+					}
+					lns = append(lns, named{name, a.Listener})
+				}
+			}
+			if len(lns) == n {
+				closeAll()
+				return fmt.Errorf("listeners[%d]: no systemd socket %q was passed to the process", i, l.Address)
+			}
+			continue
+		}
+
+		var ln net.Listener
+		if l.Network == Unix {
+			var perm os.FileMode
+			if perm, err = l.perm(); err == nil {
+				ln, err = votifier.ListenUnix(l.Address, perm)
+			}
+		} else {
+			ln, err = net.Listen("tcp", l.Address)
+		}
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("listeners[%d]: %w", i, err)
+		}
+		lns = append(lns, named{l.Name, ln})
+	}
+	for j, a := range activated {
+		if !used[j] {
+			// Not configured, don't keep the socket open.
+			a.Close()
+		}
+	}
+	for _, ln := range lns {
+		server.AddListener(ln.name, ln.Listener)
+	}
+	return nil
+}
+
+func (c *Config) loadKeyRing() (*votifier.KeyRing, error) {
+	k := c.Keys
+	key, err := c.loadKey(k.Dir, k.PrivateKey, k.Generate)
+	if err != nil {
+		return nil, fmt.Errorf("keys: %w", err)
+	}
+	ring := votifier.NewKeyRing("", key)
+	for i, p := range k.Previous {
+		key, err := c.loadKey(p.Dir, p.PrivateKey, false)
+		if err == nil {
+			err = ring.AddGraceKey("", key, p.Expires)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("keys.previous[%d]: %w", i, err)
+		}
+	}
+	return ring, nil
+}
+
+// loadKey loads a key pair from dir, or the private key privateKey.
+func (c *Config) loadKey(dir, privateKey string, generate bool) (*rsa.PrivateKey, error) {
+	if privateKey != "" {
+		encoded, err := c.resolve(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		return votifier.ParsePrivateKey(encoded)
+	}
+	key, err := votifier.LoadKeyPair(dir)
+	if !generate || !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+	if key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		return nil, err
+	}
+	return key, votifier.SaveKeyPair(dir, key)
+}
+
+// newHandler returns the listener of a handler and, if it needs to be
+// closed, its close function. Exec handlers run within a connection and
+// default to half of connTimeout.
+func newHandler(h *Handler, connTimeout time.Duration) (votifier.VoteInfoListener, func() error, error) {
+	timeout := time.Duration(h.Timeout)
+	if timeout == 0 {
+		timeout = defaultHandlerTimeout
+		if h.Type == Exec {
+			timeout = connTimeout / 2
+		}
+	}
+	switch h.Type {
+	case Log:
+		return func(v *votifier.Vote, info *votifier.VoteInfo) error {
+			log.Printf("vote from %s for %s (protocol %d, %s on %s)", v.ServiceName, v.Username, info.Protocol, info.RemoteAddr, info.Listener)
+			return nil
+		}, nil, nil
+	case File:
+		l, err := store.Open(h.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store.Listener(l, nil), l.Close, nil
+	case Webhook:
+		template := h.Template
+		if template == "discord" {
+			template = webhook.DiscordTemplate
+		}
+		wl := &webhook.Listener{
+			Endpoints: []webhook.Endpoint{{
+				Name:     h.URL,
+				URL:      h.URL,
+				Secret:   h.Secret,
+				Template: template,
+				Headers:  h.Headers,
+			}},
+			Client: &http.Client{Timeout: timeout},
+			OnDeadLetter: func(d webhook.DeadLetter) {
+				log.Printf("webhook %s: dropped vote from %s for %s: %v", d.Endpoint.URL, d.Vote.ServiceName, d.Vote.Username, d.Err)
+			},
+		}
+		listener := wl.VoteListener(nil)
+		return func(v *votifier.Vote, info *votifier.VoteInfo) error {
+			return listener(v, info.Protocol)
+		}, wl.Close, nil
+	case Exec:
+		return func(v *votifier.Vote, _ *votifier.VoteInfo) error {
+			return runCommand(h.Command, v, timeout)
+		}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unknown handler type %q", h.Type)
+}
+
+// runCommand runs command with the vote's placeholders expanded in its
+// arguments and the vote in VOTIFIER_* environment variables. Votes with
+// an invalid Minecraft username are rejected, as the command may pass it
+// on to a shell or a server console.
+func runCommand(command []string, v *votifier.Vote, timeout time.Duration) error {
+	if !placeholder.ValidUsername(v.Username) {
+		return fmt.Errorf("invalid Minecraft username %q", v.Username)
+	}
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = placeholder.Expand(arg, v, nil)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"VOTIFIER_SERVICE="+v.ServiceName,
+		"VOTIFIER_USERNAME="+v.Username,
+		"VOTIFIER_ADDRESS="+v.Address,
+		fmt.Sprintf("VOTIFIER_TIMESTAMP=%d", v.Timestamp.UnixMilli()),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command %s failed: %w: %s", args[0], err, out)
+	}
+	return nil
+}
@@ -48,5 +48,5 @@ func main() {
 		log.Fatalf("Failed to send vote: %v", err)
 	}
 
-	log.Println("Vote sent!")
+	log.Printf("Vote sent! (server version %s)", client.ServerVersion())
 }
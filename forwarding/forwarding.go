@@ -0,0 +1,105 @@
+// Package forwarding implements NuVotifier's socket based vote forwarding
+// ("proxy" forwarding method) between a proxy and its backend servers.
+//
+// A proxy such as Gate runs a Sender that re-sends every vote it receives to
+// each backend using the Votifier v2 protocol, authenticated with the token
+// configured for that backend. Backends run a regular Votifier server whose
+// tokens are looked up like NuVotifier does, so Go and Java (NuVotifier)
+// servers can be mixed behind the same vote ingress in both directions.
+package forwarding
+
+import (
+	"net"
+	"sync"
+
+	"go.minekube.com/votifier"
+)
+
+// DefaultTokenName is the service name whose token is used
+// for services without a token of their own, as in NuVotifier.
+const DefaultTokenName = votifier.DefaultTokenService
+
+// Backend is a server votes are forwarded to. It corresponds to an entry
+// in the forwarding.proxy section of NuVotifier's proxy configuration.
+type Backend struct {
+	Name    string // Identifies the backend in results, e.g. "lobby".
+	Address string // Host and port of the backend's Votifier listener.
+	Token   string // The backend's v2 token, usually its "default" token.
+}
+
+// Sender forwards votes to backend servers. It is a votifier.Proxy with
+// a v2 client per backend that forwards votes synchronously, once.
+type Sender struct {
+	Backends    []Backend
+	Concurrency int                                                        // Maximum number of parallel sends, see votifier.MultiClient.
+	OnResult    func(vote *votifier.Vote, results []votifier.TargetResult) // Optional, called after every forwarded vote.
+
+	once  sync.Once
+	proxy *votifier.Proxy
+}
+
+func (s *Sender) init() {
+	s.once.Do(func() {
+		s.proxy = &votifier.Proxy{
+			MaxAttempts: 1,
+			Concurrency: s.Concurrency,
+			OnResult:    s.OnResult,
+		}
+		for _, b := range s.Backends {
+			s.proxy.Backends = append(s.proxy.Backends, votifier.ProxyBackend{
+				Name:   b.Name,
+				Client: votifier.NewV2Client(b.Address, b.Token),
+			})
+		}
+	})
+}
+
+// Forward sends the vote to all backends and returns one result per backend.
+func (s *Sender) Forward(vote votifier.Vote) []votifier.TargetResult {
+	s.init()
+	return s.proxy.Forward(vote)
+}
+
+// VoteListener returns a listener that forwards every vote a proxy's server
+// receives. Like NuVotifier, the vote site is acknowledged regardless of
+// whether the backends could be reached; use OnResult to observe failures.
+func (s *Sender) VoteListener() votifier.VoteListener {
+	return func(vote *votifier.Vote, _ votifier.Protocol) error {
+		s.Forward(*vote)
+		return nil
+	}
+}
+
+// TokenProvider returns a token provider that resolves tokens per service
+// and falls back to the DefaultTokenName token, like NuVotifier's tokens section.
+func TokenProvider(tokens map[string]string) votifier.TokenProvider {
+	return votifier.NewMapTokenProvider(tokens)
+}
+
+// Receiver is a backend server accepting votes forwarded by a proxy.
+type Receiver struct {
+	Tokens      map[string]string     // Tokens per service, see TokenProvider.
+	VoteHandler votifier.VoteListener // Required vote handler
+	OnErr       func(net.Conn, error) // Optional connection handler
+}
+
+// Server returns the Votifier server accepting forwarded votes.
+func (r *Receiver) Server() *votifier.Server {
+	return &votifier.Server{
+		VoteHandler: r.VoteHandler,
+		Records: []votifier.ReceiverRecord{
+			{TokenProvider: TokenProvider(r.Tokens)},
+		},
+		OnErr: r.OnErr,
+	}
+}
+
+// ListenAndServe accepts forwarded votes on the given address.
+func (r *Receiver) ListenAndServe(address string) error {
+	return r.Server().ListenAndServe(address)
+}
+
+// Serve accepts forwarded votes on the provided listener.
+func (r *Receiver) Serve(ln net.Listener) error {
+	return r.Server().Serve(ln)
+}
@@ -0,0 +1,65 @@
+package forwarding
+
+import (
+	"net"
+	"testing"
+
+	"go.minekube.com/votifier"
+)
+
+func TestForwarding(t *testing.T) {
+	received := make(chan string, 2)
+	var backends []Backend
+	for _, name := range []string{"lobby", "survival"} {
+		name := name
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		r := &Receiver{
+			Tokens: map[string]string{DefaultTokenName: name + "-token"},
+			VoteHandler: func(v *votifier.Vote, p votifier.Protocol) error {
+				received <- name + ":" + v.Username
+				return nil
+			},
+		}
+		go r.Serve(ln) //nolint:errcheck
+		backends = append(backends, Backend{Name: name, Address: ln.Addr().String(), Token: name + "-token"})
+	}
+	backends = append(backends, Backend{Name: "wrong-token", Address: backends[0].Address, Token: "invalid"})
+
+	var reported []votifier.TargetResult
+	s := &Sender{
+		Backends: backends,
+		OnResult: func(_ *votifier.Vote, results []votifier.TargetResult) { reported = results },
+	}
+	err := s.VoteListener()(&votifier.Vote{ServiceName: "golang", Username: "golang"}, votifier.V2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reported) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(reported))
+	}
+	for _, r := range reported {
+		if wantErr := r.Target.Name == "wrong-token"; wantErr != (r.Err != nil) {
+			t.Errorf("unexpected result for %s: %v", r.Target.Name, r.Err)
+		}
+	}
+	got := map[string]bool{<-received: true, <-received: true}
+	if !got["lobby:golang"] || !got["survival:golang"] {
+		t.Errorf("unexpected votes received: %v", got)
+	}
+}
+
+func TestTokenProvider(t *testing.T) {
+	p := TokenProvider(map[string]string{DefaultTokenName: "default-token", "site": "site-token"})
+	if p.Token("site") != "site-token" {
+		t.Errorf("expected service token, got %q", p.Token("site"))
+	}
+	if p.Token("other") != "default-token" {
+		t.Errorf("expected default token, got %q", p.Token("other"))
+	}
+}
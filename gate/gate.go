@@ -0,0 +1,122 @@
+// Package gate runs a Votifier server inside the Gate Minecraft proxy
+// (https://github.com/minekube/gate) and fires a VoteEvent on Gate's
+// event manager for every accepted vote.
+//
+// The package does not depend on Gate itself. Wire it up in a Gate plugin:
+//
+//	proxy.Plugins = append(proxy.Plugins, proxy.Plugin{
+//		Name: "votifier",
+//		Init: func(ctx context.Context, p *proxy.Proxy) error {
+//			v := &gate.Plugin{
+//				Address: ":8192",
+//				Records: records,
+//				FindPlayer: func(username string) gate.Player {
+//					if player := p.PlayerByName(username); player != nil {
+//						return player
+//					}
+//					return nil
+//				},
+//				Fire: func(e any) { p.Event().Fire(e) },
+//			}
+//			go v.Start(ctx)
+//			return nil
+//		},
+//	})
+//
+// and subscribe to votes with event.Subscribe(p.Event(), 0, func(e *gate.VoteEvent) { ... }).
+package gate
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"go.minekube.com/votifier"
+)
+
+// Player is the part of Gate's proxy.Player used by this package.
+type Player interface {
+	Username() string
+}
+
+// VoteEvent is fired on Gate's event manager when a vote was received.
+type VoteEvent struct {
+	vote     *votifier.Vote
+	protocol votifier.Protocol
+	player   Player
+}
+
+// Vote returns the received vote.
+func (e *VoteEvent) Vote() *votifier.Vote { return e.vote }
+
+// Protocol returns the protocol the vote was received with.
+func (e *VoteEvent) Protocol() votifier.Protocol { return e.protocol }
+
+// Player returns the online player the vote is for, or nil if the player is offline.
+func (e *VoteEvent) Player() Player { return e.player }
+
+// Online reports whether the player the vote is for is connected to the proxy.
+func (e *VoteEvent) Online() bool { return e.player != nil }
+
+// Plugin runs a Votifier server inside a Gate proxy.
+type Plugin struct {
+	Address    string                       // Address to listen on, e.g. ":8192".
+	Records    []votifier.ReceiverRecord    // Keys and tokens to accept votes with.
+	FindPlayer func(username string) Player // Required, resolves online players, e.g. using proxy.PlayerByName.
+	Fire       func(event any)              // Required, fires events, e.g. using proxy.Event().Fire.
+	OnErr      func(net.Conn, error)        // Optional connection handler
+}
+
+// VoteListener returns a listener firing a VoteEvent for every vote.
+func (p *Plugin) VoteListener() votifier.VoteListener {
+	return func(vote *votifier.Vote, protocol votifier.Protocol) error {
+		p.Fire(&VoteEvent{
+			vote:     vote,
+			protocol: protocol,
+			player:   p.FindPlayer(vote.Username),
+		})
+		return nil
+	}
+}
+
+// Server returns the Votifier server firing vote events.
+func (p *Plugin) Server() *votifier.Server {
+	return &votifier.Server{
+		VoteHandler: p.VoteListener(),
+		Records:     p.Records,
+		OnErr:       p.OnErr,
+	}
+}
+
+// Start listens on Address and serves votes until the context is canceled.
+func (p *Plugin) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", p.Address)
+	if err != nil {
+		return err
+	}
+	return p.Serve(ctx, ln)
+}
+
+// Serve serves votes on the listener until the context is canceled.
+// The listener is closed when Serve returns.
+func (p *Plugin) Serve(ctx context.Context, ln net.Listener) error {
+	if p.FindPlayer == nil || p.Fire == nil {
+		ln.Close()
+		return errors.New("FindPlayer and Fire are required")
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		ln.Close()
+	}()
+
+	err := p.Server().Serve(ln)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
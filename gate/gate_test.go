@@ -0,0 +1,84 @@
+package gate
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+type fakePlayer string
+
+func (p fakePlayer) Username() string { return string(p) }
+
+// fakeEventManager records fired events like Gate's event manager would dispatch them.
+type fakeEventManager struct {
+	mu     sync.Mutex
+	events []any
+	fired  chan struct{}
+}
+
+func (m *fakeEventManager) Fire(e any) {
+	m.mu.Lock()
+	m.events = append(m.events, e)
+	m.mu.Unlock()
+	m.fired <- struct{}{}
+}
+
+func TestPlugin(t *testing.T) {
+	online := map[string]Player{"alice": fakePlayer("Alice")}
+	events := &fakeEventManager{fired: make(chan struct{}, 2)}
+	p := &Plugin{
+		Records: []votifier.ReceiverRecord{{TokenProvider: votifier.StaticTokenProvider("abcxyz")}},
+		FindPlayer: func(username string) Player {
+			if player, ok := online[strings.ToLower(username)]; ok {
+				return player
+			}
+			return nil
+		},
+		Fire: events.Fire,
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- p.Serve(ctx, ln) }()
+
+	client := votifier.NewV2Client(ln.Addr().String(), "abcxyz")
+	for _, username := range []string{"Alice", "bob"} {
+		if err = client.SendVote(votifier.Vote{ServiceName: "golang", Username: username}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-events.fired:
+		case <-time.After(5 * time.Second):
+			t.Fatal("event was not fired")
+		}
+	}
+
+	cancel()
+	if err = <-served; err != nil {
+		t.Errorf("expected nil error after cancel, got %v", err)
+	}
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	if len(events.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events.events))
+	}
+	alice := events.events[0].(*VoteEvent)
+	if !alice.Online() || alice.Player().Username() != "Alice" || alice.Protocol() != votifier.V2 {
+		t.Errorf("unexpected event for online player: %+v", alice)
+	}
+	bob := events.events[1].(*VoteEvent)
+	if bob.Online() || bob.Player() != nil || bob.Vote().Username != "bob" {
+		t.Errorf("unexpected event for offline player: %+v", bob)
+	}
+}
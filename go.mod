@@ -1,3 +1,7 @@
 module go.minekube.com/votifier
 
 go 1.19
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/BurntSushi/toml v1.3.2
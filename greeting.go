@@ -0,0 +1,75 @@
+package votifier
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInvalidGreeting is returned when a server does not greet like a Votifier server.
+var ErrInvalidGreeting = errors.New("invalid votifier greeting")
+
+// maxGreetingSize is the maximum length of a greeting line we accept.
+const maxGreetingSize = 256
+
+// Greeting is the line a Votifier server sends after a client connects.
+type Greeting struct {
+	Version   string // The advertised server version, e.g. "1.9" or "2".
+	Challenge string // The v2 challenge, empty if the server only speaks v1.
+}
+
+// ParseGreeting parses a greeting line such as "VOTIFIER 1.9" or "VOTIFIER 2 <challenge>".
+// A trailing line ending is ignored.
+func ParseGreeting(line string) (*Greeting, error) {
+	line = strings.TrimRight(line, "\r\n")
+	parts := strings.Split(line, " ")
+	if parts[0] != "VOTIFIER" || len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidGreeting, line)
+	}
+	g := &Greeting{Version: parts[1]}
+	if len(parts) == 3 {
+		if parts[2] == "" {
+			return nil, fmt.Errorf("%w: empty challenge in %q", ErrInvalidGreeting, line)
+		}
+		g.Challenge = parts[2]
+	}
+	return g, nil
+}
+
+// readGreeting reads and parses the greeting line from r.
+func readGreeting(r *bufio.Reader) (*Greeting, error) {
+	var line []byte
+	for {
+		fragment, err := r.ReadSlice('\n')
+		line = append(line, fragment...)
+		if len(line) > maxGreetingSize {
+			return nil, fmt.Errorf("%w: exceeds %d bytes", ErrInvalidGreeting, maxGreetingSize)
+		}
+		if err == nil {
+			break
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			if len(line) == 0 {
+				return nil, fmt.Errorf("error reading greeting: %w", io.ErrUnexpectedEOF)
+			}
+			return nil, fmt.Errorf("%w: missing line ending in %q", ErrInvalidGreeting, line)
+		}
+		return nil, fmt.Errorf("error reading greeting: %w", err)
+	}
+	return ParseGreeting(string(line))
+}
+
+// Probe connects to a server and returns its greeting without sending a vote.
+func Probe(address string) (*Greeting, error) {
+	conn, err := dial(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return readGreeting(bufio.NewReader(conn))
+}
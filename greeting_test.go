@@ -0,0 +1,71 @@
+package votifier
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseGreeting(t *testing.T) {
+	tests := []struct {
+		line      string
+		version   string
+		challenge string
+		wantErr   bool
+	}{
+		{line: "VOTIFIER 1.9\n", version: "1.9"},
+		{line: "VOTIFIER 2 abcxyz\n", version: "2", challenge: "abcxyz"},
+		{line: "VOTIFIER 2 abcxyz\r\n", version: "2", challenge: "abcxyz"},
+		{line: "VOTIFIER\n", wantErr: true},
+		{line: "VOTIFIER 2 abc xyz\n", wantErr: true},
+		{line: "SSH-2.0-OpenSSH_9.0\n", wantErr: true},
+		{line: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		g, err := ParseGreeting(tt.line)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidGreeting) {
+				t.Errorf("ParseGreeting(%q): expected ErrInvalidGreeting, got %v", tt.line, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseGreeting(%q): %v", tt.line, err)
+			continue
+		}
+		if g.Version != tt.version || g.Challenge != tt.challenge {
+			t.Errorf("ParseGreeting(%q) = %+v", tt.line, g)
+		}
+	}
+}
+
+func TestReadGreetingTooLong(t *testing.T) {
+	line := "VOTIFIER 2 " + strings.Repeat("a", 2*maxGreetingSize) + "\n"
+	_, err := readGreeting(bufio.NewReaderSize(strings.NewReader(line), 16))
+	if !errors.Is(err, ErrInvalidGreeting) {
+		t.Errorf("expected ErrInvalidGreeting, got %v", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server := Server{
+		VoteHandler: func(*Vote, Protocol) error { return nil },
+		Records:     []ReceiverRecord{{TokenProvider: StaticTokenProvider("abcxyz")}},
+	}
+	go server.Serve(listener) //nolint:errcheck
+
+	g, err := Probe(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Version != "2" || g.Challenge == "" {
+		t.Errorf("unexpected greeting %+v", g)
+	}
+}
@@ -0,0 +1,169 @@
+// Package health provides liveness and readiness endpoints for a Votifier
+// server, with a readiness check that sends real votes through the server.
+//
+// The self-test sends a synthetic vote for SelfTestService with a random
+// username through votifier.V2Client (and votifier.V1Client, if a public key
+// is configured) and waits for the server to decode it. Self-test votes are
+// intercepted by the Checker's listener, never reach the real vote handler
+// and are not counted in the server's Stats:
+//
+//	checker := &health.Checker{Address: "127.0.0.1:8192", Token: token}
+//	server.VoteHandler = checker.Listener(handleVote)
+//	http.Handle("/", checker.Handler())
+package health
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+// SelfTestService is the service name of self-test votes. The server's token
+// provider must return the Checker's token for it.
+const SelfTestService = "votifier-selftest"
+
+// Checker checks that a server accepts votes end to end.
+type Checker struct {
+	Address   string         // Required, address the server listens on.
+	Token     string         // Required, v2 token of SelfTestService.
+	PublicKey *rsa.PublicKey // Optional, also tests v1 if set.
+	Timeout   time.Duration  // Maximum duration of a check, defaults to 5 seconds.
+	CacheTTL  time.Duration  // How long /readyz reuses a check result, defaults to 10 seconds.
+
+	mu      sync.Mutex
+	pending map[string]chan votifier.Protocol // username -> waiting check
+
+	cacheMu sync.Mutex
+	checked time.Time
+	lastErr error
+}
+
+// Listener returns a listener intercepting self-test votes and passing
+// all other votes on to next. It returns votifier.SkipCount for self-test
+// votes, so it must be the outermost listener of the server.
+func (c *Checker) Listener(next votifier.VoteListener) votifier.VoteListener {
+	return func(vote *votifier.Vote, protocol votifier.Protocol) error {
+		if vote.ServiceName == SelfTestService {
+			return c.intercept(vote, protocol)
+		}
+		return next(vote, protocol)
+	}
+}
+
+// InfoListener is like Listener for servers using a votifier.VoteInfoListener.
+func (c *Checker) InfoListener(next votifier.VoteInfoListener) votifier.VoteInfoListener {
+	return func(vote *votifier.Vote, info *votifier.VoteInfo) error {
+		if vote.ServiceName == SelfTestService {
+			return c.intercept(vote, info.Protocol)
+		}
+		return next(vote, info)
+	}
+}
+
+func (c *Checker) intercept(vote *votifier.Vote, protocol votifier.Protocol) error {
+	c.mu.Lock()
+	ch, ok := c.pending[vote.Username]
+	delete(c.pending, vote.Username)
+	c.mu.Unlock()
+	if !ok {
+		return errors.New("unexpected self-test vote")
+	}
+	ch <- protocol
+	return votifier.SkipCount
+}
+
+// Check sends self-test votes to the server and waits until they are decoded.
+func (c *Checker) Check() error {
+	if err := c.check(votifier.V2, func(v votifier.Vote) error {
+		return votifier.NewV2Client(c.Address, c.Token).SendVote(v)
+	}); err != nil {
+		return fmt.Errorf("v2 self-test: %w", err)
+	}
+	if c.PublicKey == nil {
+		return nil
+	}
+	if err := c.check(votifier.V1, func(v votifier.Vote) error {
+		return votifier.NewV1Client(c.Address, c.PublicKey).SendVote(v)
+	}); err != nil {
+		return fmt.Errorf("v1 self-test: %w", err)
+	}
+	return nil
+}
+
+func (c *Checker) check(protocol votifier.Protocol, send func(votifier.Vote) error) error {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	username := hex.EncodeToString(nonce)
+	ch := make(chan votifier.Protocol, 1)
+	c.mu.Lock()
+	if c.pending == nil {
+		c.pending = map[string]chan votifier.Protocol{}
+	}
+	c.pending[username] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, username)
+		c.mu.Unlock()
+	}()
+
+	err := send(votifier.Vote{ServiceName: SelfTestService, Username: username, Address: "127.0.0.1"})
+	if err != nil {
+		return err
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	select {
+	case got := <-ch:
+		if got != protocol {
+			return fmt.Errorf("vote was received with protocol %d", got)
+		}
+		return nil
+	case <-time.After(timeout):
+		return errors.New("vote was not received by the server")
+	}
+}
+
+// cachedCheck returns the result of the last check if it is recent enough.
+func (c *Checker) cachedCheck() error {
+	ttl := c.CacheTTL
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if time.Since(c.checked) < ttl {
+		return c.lastErr
+	}
+	c.lastErr = c.Check()
+	c.checked = time.Now()
+	return c.lastErr
+}
+
+// Handler returns a handler serving /healthz, which reports that the
+// process is alive, and /readyz, which runs the self-test.
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.cachedCheck(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	return mux
+}
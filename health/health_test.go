@@ -0,0 +1,87 @@
+package health
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+func TestChecker(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	checker := &Checker{
+		Address:   ln.Addr().String(),
+		Token:     "selftest-token",
+		PublicKey: &key.PublicKey,
+		Timeout:   time.Second,
+		CacheTTL:  time.Nanosecond,
+	}
+	var handled int32
+	server := &votifier.Server{
+		VoteHandler: checker.Listener(func(*votifier.Vote, votifier.Protocol) error {
+			atomic.AddInt32(&handled, 1)
+			return nil
+		}),
+		Records: []votifier.ReceiverRecord{{
+			PrivateKey:    key,
+			TokenProvider: votifier.NewMapTokenProvider(map[string]string{SelfTestService: "selftest-token"}),
+		}},
+	}
+	go server.Serve(ln) //nolint:errcheck
+
+	srv := httptest.NewServer(checker.Handler())
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+
+	if status, _ := get("/healthz"); status != http.StatusOK {
+		t.Errorf("expected healthy, got %d", status)
+	}
+	if status, body := get("/readyz"); status != http.StatusOK {
+		t.Errorf("expected ready, got %d: %s", status, body)
+	}
+	if n := atomic.LoadInt32(&handled); n != 0 {
+		t.Errorf("expected self-test votes to not reach the vote handler, got %d", n)
+	}
+	if n := server.Stats().Votes; n != 0 {
+		t.Errorf("expected self-test votes to not be counted, got %d", n)
+	}
+
+	// A wrong token makes the server reject the self-test vote.
+	checker.Token = "wrong"
+	status, body := get("/readyz")
+	if status != http.StatusServiceUnavailable || !strings.Contains(body, "v2 self-test") {
+		t.Errorf("expected not ready, got %d: %s", status, body)
+	}
+
+	// A stopped server is not ready.
+	checker.Token = "selftest-token"
+	ln.Close()
+	if status, _ = get("/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready after stopping the server, got %d", status)
+	}
+}
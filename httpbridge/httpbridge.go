@@ -0,0 +1,190 @@
+// Package httpbridge accepts votes from vote sites that only support HTTP webhooks.
+//
+// A vote is submitted as a POST request with a JSON body:
+//
+//	{"serviceName":"site","username":"Notch","address":"1.2.3.4","timestamp":1700000000000,"nonce":"b5c1..."}
+//
+// signed with HMAC-SHA256 using the service's v2 token, sent in the
+// X-Votifier-Signature header as "sha256=<hex>". The timestamp is in Unix
+// milliseconds and must be within MaxSkew of the server's clock, and every
+// nonce is only accepted once, so captured requests cannot be replayed.
+package httpbridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/internal/signature"
+)
+
+// Submission is the JSON body of a vote request.
+type Submission struct {
+	ServiceName string `json:"serviceName"`
+	Username    string `json:"username"`
+	Address     string `json:"address"`
+	Timestamp   int64  `json:"timestamp"` // Unix milliseconds
+	Nonce       string `json:"nonce"`     // Random string, unique per submission.
+}
+
+type response struct {
+	Status string `json:"status"`
+	Cause  string `json:"cause,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Handler is an http.Handler accepting signed vote submissions and passing
+// them to the same listeners a votifier.Server uses, with votifier.HTTP as protocol.
+type Handler struct {
+	TokenProvider   votifier.TokenProvider    // Required, tokens per service.
+	VoteHandler     votifier.VoteListener     // Required vote handler, unless VoteInfoHandler is set
+	VoteInfoHandler votifier.VoteInfoListener // Optional, used instead of VoteHandler if set
+	MaxSkew         time.Duration             // Maximum clock difference, defaults to 5 minutes.
+	MaxBodySize     int64                     // Defaults to 4 KiB.
+
+	mu     sync.Mutex
+	nonces map[string]time.Time // nonce -> time until which it is remembered
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+func (h *Handler) maxSkew() time.Duration {
+	if h.MaxSkew <= 0 {
+		return 5 * time.Minute
+	}
+	return h.MaxSkew
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method", errors.New("only POST is allowed"))
+		return
+	}
+	maxBody := h.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = 4 << 10
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "decode", err)
+		return
+	}
+
+	var sub Submission
+	if err = json.Unmarshal(body, &sub); err != nil {
+		writeError(w, http.StatusBadRequest, "decode", err)
+		return
+	}
+	if sub.ServiceName == "" || sub.Username == "" || sub.Nonce == "" {
+		writeError(w, http.StatusBadRequest, "decode", errors.New("serviceName, username and nonce are required"))
+		return
+	}
+
+	var tokenID string
+	for _, token := range votifier.TokensOf(h.TokenProvider, sub.ServiceName) {
+		if token != "" && signature.Verify(token, body, r.Header.Get(signature.Header)) {
+			tokenID = votifier.TokenID(token)
+			break
+		}
+	}
+	if tokenID == "" {
+		writeError(w, http.StatusUnauthorized, "signature", errors.New("invalid signature"))
+		return
+	}
+
+	now := time.Now()
+	ts := time.UnixMilli(sub.Timestamp)
+	if skew := now.Sub(ts); skew > h.maxSkew() || -skew > h.maxSkew() {
+		writeError(w, http.StatusBadRequest, "timestamp", fmt.Errorf("timestamp is %s off", skew.Round(time.Second)))
+		return
+	}
+	if !h.useNonce(sub.ServiceName+"\x00"+sub.Nonce, now) {
+		writeError(w, http.StatusConflict, "replay", errors.New("nonce was already used"))
+		return
+	}
+
+	vote := &votifier.Vote{
+		ServiceName: sub.ServiceName,
+		Username:    sub.Username,
+		Address:     sub.Address,
+		Timestamp:   ts,
+	}
+	if h.VoteInfoHandler != nil {
+		info := &votifier.VoteInfo{Protocol: votifier.HTTP, TokenID: tokenID}
+		if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+			info.RemoteAddr = addr
+		}
+		err = h.VoteInfoHandler(vote, info)
+	} else {
+		err = h.VoteHandler(vote, votifier.HTTP)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "handler", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response{Status: "ok"})
+}
+
+// useNonce remembers the nonce and reports whether it was unused.
+// Nonces are forgotten once their timestamps would be rejected anyway.
+func (h *Handler) useNonce(nonce string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.nonces == nil {
+		h.nonces = map[string]time.Time{}
+	}
+	for n, until := range h.nonces {
+		if now.After(until) {
+			delete(h.nonces, n)
+		}
+	}
+	if _, used := h.nonces[nonce]; used {
+		return false
+	}
+	h.nonces[nonce] = now.Add(2 * h.maxSkew())
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, cause string, err error) {
+	writeJSON(w, status, response{Status: "error", Cause: cause, Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// NewRequest returns a signed request submitting the vote to the bridge at url.
+// The nonce must be unique, e.g. a random string.
+func NewRequest(url, token, nonce string, vote votifier.Vote) (*http.Request, error) {
+	if vote.Timestamp.IsZero() {
+		vote.Timestamp = time.Now()
+	}
+	body, err := json.Marshal(Submission{
+		ServiceName: vote.ServiceName,
+		Username:    vote.Username,
+		Address:     vote.Address,
+		Timestamp:   vote.Timestamp.UnixMilli(),
+		Nonce:       nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signature.Header, signature.Sign(token, body))
+	return req, nil
+}
@@ -0,0 +1,80 @@
+package httpbridge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+func TestHandler(t *testing.T) {
+	var votes []*votifier.Vote
+	var infos []*votifier.VoteInfo
+	h := &Handler{
+		TokenProvider: votifier.TokenProviderFunc(func(service string) string {
+			if service == "site" {
+				return "abcxyz"
+			}
+			return ""
+		}),
+		VoteInfoHandler: func(v *votifier.Vote, info *votifier.VoteInfo) error {
+			votes = append(votes, v)
+			infos = append(infos, info)
+			return nil
+		},
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	do := func(token, nonce string, vote votifier.Vote) int {
+		req, err := NewRequest(srv.URL, token, nonce, vote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	vote := votifier.Vote{ServiceName: "site", Username: "golang", Address: "1.2.3.4"}
+	tests := []struct {
+		name   string
+		token  string
+		nonce  string
+		vote   votifier.Vote
+		status int
+	}{
+		{"valid", "abcxyz", "n1", vote, http.StatusOK},
+		{"replayed nonce", "abcxyz", "n1", vote, http.StatusConflict},
+		{"wrong token", "wrong", "n2", vote, http.StatusUnauthorized},
+		{"unknown service", "", "n3", votifier.Vote{ServiceName: "other", Username: "golang"}, http.StatusUnauthorized},
+		{"stale timestamp", "abcxyz", "n4", votifier.Vote{ServiceName: "site", Username: "golang", Timestamp: time.Now().Add(-time.Hour)}, http.StatusBadRequest},
+		{"missing username", "abcxyz", "n5", votifier.Vote{ServiceName: "site"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if got := do(tt.token, tt.nonce, tt.vote); got != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, got)
+		}
+	}
+
+	if len(votes) != 1 || votes[0].Username != "golang" || votes[0].Address != "1.2.3.4" {
+		t.Fatalf("expected one accepted vote, got %+v", votes)
+	}
+	if infos[0].Protocol != votifier.HTTP || infos[0].RemoteAddr == nil {
+		t.Errorf("unexpected vote info %+v", infos[0])
+	}
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET to be rejected, got %d", res.StatusCode)
+	}
+}
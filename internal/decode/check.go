@@ -0,0 +1,175 @@
+package decode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// check reports the first value of the JSON document data that can't be
+// decoded into t, prefixed with its path such as "handlers[1].timeout".
+// encoding/json names neither the path of unknown fields nor of errors
+// returned by UnmarshalJSON methods.
+func check(data []byte, t reflect.Type) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	return checkValue(doc, t, "")
+}
+
+func checkValue(doc any, t reflect.Type, path string) error {
+	if doc == nil {
+		return nil
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if err = reflect.New(t).Interface().(json.Unmarshaler).UnmarshalJSON(raw); err != nil {
+			return pathError(path, err)
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return checkValue(doc, t.Elem(), path)
+	case reflect.Interface:
+		return nil
+	case reflect.Struct:
+		m, ok := doc.(map[string]any)
+		if !ok {
+			return typeError(path, "an object", doc)
+		}
+		for key, value := range m {
+			f, ok := field(t, key)
+			if !ok {
+				return pathError(path, fmt.Errorf("unknown field %q", key))
+			}
+			if err := checkValue(value, f.Type, join(path, key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := doc.(map[string]any)
+		if !ok {
+			return typeError(path, "an object", doc)
+		}
+		for key, value := range m {
+			if err := checkValue(value, t.Elem(), join(path, key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := doc.([]any)
+		if !ok {
+			return typeError(path, "a list", doc)
+		}
+		for i, value := range s {
+			if err := checkValue(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if _, ok := doc.(string); !ok {
+			return typeError(path, "a string", doc)
+		}
+	case reflect.Bool:
+		if _, ok := doc.(bool); !ok {
+			return typeError(path, "a boolean", doc)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := doc.(json.Number)
+		if !ok {
+			return typeError(path, "an integer", doc)
+		}
+		if _, err := strconv.ParseInt(string(n), 10, 64); err != nil {
+			return typeError(path, "an integer", doc)
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := doc.(json.Number); !ok {
+			return typeError(path, "a number", doc)
+		}
+	}
+	return nil
+}
+
+// field returns the struct field decoded from the JSON key, matching
+// names case-insensitively and promoting fields of embedded structs like
+// encoding/json.
+func field(t reflect.Type, key string) (reflect.StructField, bool) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	for _, et := range embedded {
+		if f, ok := field(et, key); ok {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func pathError(path string, err error) error {
+	if path == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+func typeError(path, want string, doc any) error {
+	var got string
+	switch doc.(type) {
+	case map[string]any:
+		got = "an object"
+	case []any:
+		got = "a list"
+	case string:
+		got = "a string"
+	case bool:
+		got = "a boolean"
+	default:
+		got = "a number"
+	}
+	return pathError(path, fmt.Errorf("expected %s, got %s", want, got))
+}
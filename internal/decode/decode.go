@@ -0,0 +1,86 @@
+// Package decode decodes JSON, YAML and TOML configuration files into
+// structs using their json struct tags.
+package decode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats supported by Unmarshal.
+const (
+	JSON = "json"
+	YAML = "yaml"
+	TOML = "toml"
+)
+
+// FormatOf returns the format of a file by its extension, defaulting to YAML.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON
+	case ".toml":
+		return TOML
+	}
+	return YAML
+}
+
+// File decodes the file at path into v.
+func File(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err = Unmarshal(data, FormatOf(path), v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Unmarshal decodes data in the given format into v. YAML and TOML are
+// converted to JSON first, so v only needs json struct tags. Unknown fields are
+// errors. Errors name the offending field, e.g. "handlers[1].timeout".
+func Unmarshal(data []byte, format string, v any) error {
+	switch format {
+	case JSON:
+	case YAML:
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		if doc == nil {
+			doc = map[string]any{}
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return err
+		}
+	case TOML:
+		var doc map[string]any
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Pointer {
+		if err := check(data, t.Elem()); err != nil {
+			return err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,43 @@
+// Package placeholder expands vote placeholders such as {username} in templates.
+package placeholder
+
+import (
+	"strconv"
+	"strings"
+
+	"go.minekube.com/votifier"
+)
+
+// Expand replaces {username}, {service}, {address} and {timestamp} (Unix
+// milliseconds) with the values of the vote, and {key} with the value of
+// key in extra.
+func Expand(template string, vote *votifier.Vote, extra map[string]string) string {
+	if !strings.Contains(template, "{") {
+		return template
+	}
+	pairs := []string{
+		"{username}", vote.Username,
+		"{service}", vote.ServiceName,
+		"{address}", vote.Address,
+		"{timestamp}", strconv.FormatInt(vote.Timestamp.UnixMilli(), 10),
+	}
+	for k, v := range extra {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// ValidUsername reports whether name is a valid Minecraft username: 1 to 16
+// letters, digits and underscores. Usernames expanded into commands must
+// be valid, or a vote could inject arguments or target selectors such as "@a".
+func ValidUsername(name string) bool {
+	if len(name) == 0 || len(name) > 16 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
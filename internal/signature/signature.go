@@ -0,0 +1,35 @@
+// Package signature signs and verifies HTTP request bodies with HMAC-SHA256.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Header is the HTTP header carrying the signature of a request body.
+const Header = "X-Votifier-Signature"
+
+const prefix = "sha256="
+
+// Sign returns the signature of body in the form "sha256=<hex>".
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return prefix + hex.EncodeToString(m.Sum(nil))
+}
+
+// Verify reports whether sig is a valid signature of body.
+func Verify(secret string, body []byte, sig string) bool {
+	if !strings.HasPrefix(sig, prefix) {
+		return false
+	}
+	got, err := hex.DecodeString(sig[len(prefix):])
+	if err != nil {
+		return false
+	}
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return hmac.Equal(got, m.Sum(nil))
+}
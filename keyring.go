@@ -0,0 +1,194 @@
+package votifier
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyRing holds the active RSA key of a v1 receiver and the keys it
+// replaced, which keep decrypting votes until they expire. This lets vote
+// sites switch to a new public key at their own pace after a rotation.
+//
+// The ring counts the votes each key decrypted, see Keys, so that a grace
+// key can be retired once vote sites stopped using it.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys []*ringKey // active key first, then grace keys in the order they are tried
+}
+
+type ringKey struct {
+	id       string
+	key      *rsa.PrivateKey
+	expires  time.Time // zero for the active key
+	votes    atomic.Uint64
+	lastUsed atomic.Int64 // Unix nanoseconds
+}
+
+// KeyInfo describes a key of a KeyRing.
+type KeyInfo struct {
+	ID       string    `json:"id"`
+	Active   bool      `json:"active"`
+	Expires  time.Time `json:"expires,omitempty"` // Zero for the active key.
+	Expired  bool      `json:"expired"`
+	Votes    uint64    `json:"votes"`              // Votes decrypted with the key.
+	LastUsed time.Time `json:"lastUsed,omitempty"` // Zero if the key decrypted no votes.
+}
+
+// KeyID returns an identifier of a public key: the first 8 bytes of the
+// SHA-256 hash of its PKIX form, in hex.
+func KeyID(key *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewKeyRing returns a key ring with an active key. If id is empty, the
+// KeyID of the key is used.
+func NewKeyRing(id string, key *rsa.PrivateKey) *KeyRing {
+	if id == "" {
+		id = KeyID(&key.PublicKey)
+	}
+	return &KeyRing{keys: []*ringKey{{id: id, key: key}}}
+}
+
+// Active returns the ID and key votes should be encrypted for.
+func (r *KeyRing) Active() (string, *rsa.PrivateKey) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys[0].id, r.keys[0].key
+}
+
+// Rotate makes key the active key. The previously active key is kept as a
+// grace key for the grace period. Expired grace keys are removed. If id is
+// empty, the KeyID of the key is used.
+func (r *KeyRing) Rotate(id string, key *rsa.PrivateKey, grace time.Duration) error {
+	if id == "" {
+		id = KeyID(&key.PublicKey)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneLocked()
+	if r.indexLocked(id) >= 0 {
+		return fmt.Errorf("key %s is already in the key ring", id)
+	}
+	r.keys[0].expires = timeNow().Add(grace)
+	r.keys = append([]*ringKey{{id: id, key: key}}, r.keys...)
+	return nil
+}
+
+// AddGraceKey adds a previously active key that decrypts votes until it
+// expires, e.g. when loading a rotated key on startup. Expired grace keys
+// are removed. If id is empty, the KeyID of the key is used.
+func (r *KeyRing) AddGraceKey(id string, key *rsa.PrivateKey, expires time.Time) error {
+	if id == "" {
+		id = KeyID(&key.PublicKey)
+	}
+	if expires.IsZero() {
+		return errors.New("grace keys must expire")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneLocked()
+	if r.indexLocked(id) >= 0 {
+		return fmt.Errorf("key %s is already in the key ring", id)
+	}
+	r.keys = append(r.keys, &ringKey{id: id, key: key, expires: expires})
+	return nil
+}
+
+// Retire removes a grace key. It reports whether the key was found; the
+// active key can't be retired.
+func (r *KeyRing) Retire(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.indexLocked(id)
+	if i <= 0 {
+		return false
+	}
+	r.keys = append(r.keys[:i:i], r.keys[i+1:]...)
+	return true
+}
+
+// pruneLocked removes the expired grace keys.
+func (r *KeyRing) pruneLocked() {
+	now := timeNow()
+	keys := r.keys[:1]
+	for _, k := range r.keys[1:] {
+		if now.Before(k.expires) {
+			keys = append(keys, k)
+		}
+	}
+	for i := len(keys); i < len(r.keys); i++ {
+		r.keys[i] = nil
+	}
+	r.keys = keys
+}
+
+func (r *KeyRing) indexLocked(id string) int {
+	for i, k := range r.keys {
+		if k.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Keys describes the keys of the ring, the active key first.
+func (r *KeyRing) Keys() []KeyInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := timeNow()
+	infos := make([]KeyInfo, len(r.keys))
+	for i, k := range r.keys {
+		infos[i] = KeyInfo{
+			ID:      k.id,
+			Active:  i == 0,
+			Expires: k.expires,
+			Expired: i != 0 && !now.Before(k.expires),
+			Votes:   k.votes.Load(),
+		}
+		if ns := k.lastUsed.Load(); ns != 0 {
+			infos[i].LastUsed = time.Unix(0, ns)
+		}
+	}
+	return infos
+}
+
+// DecodeV1KeyRing decodes the vote from the V1 protocol, trying the active
+// key and then the unexpired grace keys of the ring. It returns the ID of
+// the key that decrypted the vote.
+func (v *Vote) DecodeV1KeyRing(data []byte, ring *KeyRing) (string, error) {
+	now := timeNow()
+	ring.mu.RLock()
+	keys := make([]*ringKey, 0, len(ring.keys))
+	for i, k := range ring.keys {
+		if i == 0 || now.Before(k.expires) {
+			keys = append(keys, k)
+		}
+	}
+	ring.mu.RUnlock()
+
+	var firstErr error
+	for _, k := range keys {
+		err := v.DecodeV1(data, k.key)
+		if err == nil {
+			k.votes.Add(1)
+			k.lastUsed.Store(now.UnixNano())
+			return k.id, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", firstErr
+}
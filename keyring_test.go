@@ -0,0 +1,177 @@
+package votifier
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func generateKeys(t *testing.T, n int) []*rsa.PrivateKey {
+	t.Helper()
+	keys := make([]*rsa.PrivateKey, n)
+	for i := range keys {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// stubNow makes timeNow return now until the test finished.
+func stubNow(t *testing.T, now time.Time) {
+	prev := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = prev })
+}
+
+func encodeV1(t *testing.T, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	v := Vote{ServiceName: "golang", Username: "golang", Address: "127.0.0.1"}
+	data, err := v.EncodeV1(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *data
+}
+
+func TestKeyRingRotate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	stubNow(t, now)
+	keys := generateKeys(t, 3)
+	ring := NewKeyRing("old", keys[0])
+	if err := ring.Rotate("new", keys[1], time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Rotate("new", keys[2], time.Hour); err == nil {
+		t.Error("expected an error rotating to a used ID")
+	}
+	if id, key := ring.Active(); id != "new" || key != keys[1] {
+		t.Errorf("expected the new key to be active, got %s", id)
+	}
+
+	for _, tt := range []struct {
+		key    *rsa.PrivateKey
+		wantID string
+	}{{keys[1], "new"}, {keys[0], "old"}, {keys[0], "old"}} {
+		var v Vote
+		id, err := v.DecodeV1KeyRing(encodeV1(t, tt.key), ring)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != tt.wantID || v.Username != "golang" {
+			t.Errorf("expected vote decrypted by %s, got %s: %+v", tt.wantID, id, v)
+		}
+	}
+	var v Vote
+	if _, err := v.DecodeV1KeyRing(encodeV1(t, keys[2]), ring); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+
+	infos := ring.Keys()
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 keys, got %+v", infos)
+	}
+	if infos[0].ID != "new" || !infos[0].Active || infos[0].Votes != 1 || !infos[0].Expires.IsZero() {
+		t.Errorf("unexpected active key %+v", infos[0])
+	}
+	if infos[1].ID != "old" || infos[1].Active || infos[1].Votes != 2 || !infos[1].Expires.Equal(now.Add(time.Hour)) || !infos[1].LastUsed.Equal(now) {
+		t.Errorf("unexpected grace key %+v", infos[1])
+	}
+
+	// The old key stops decrypting votes once it expired.
+	stubNow(t, now.Add(time.Hour))
+	if _, err := v.DecodeV1KeyRing(encodeV1(t, keys[0]), ring); err == nil {
+		t.Error("expected an error for an expired key")
+	}
+	if infos = ring.Keys(); !infos[1].Expired {
+		t.Errorf("expected the grace key to be expired, got %+v", infos[1])
+	}
+
+	if ring.Retire("new") {
+		t.Error("the active key must not be retired")
+	}
+	if !ring.Retire("old") || len(ring.Keys()) != 1 {
+		t.Errorf("expected the old key to be retired, got %+v", ring.Keys())
+	}
+}
+
+func TestKeyRingRotatePrunesExpiredKeys(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	stubNow(t, now)
+	keys := generateKeys(t, 4)
+	ring := NewKeyRing("k0", keys[0])
+	for i := 1; i < len(keys); i++ {
+		stubNow(t, now.Add(time.Duration(i)*time.Hour))
+		if err := ring.Rotate(fmt.Sprintf("k%d", i), keys[i], time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each rotation removed the grace key that expired before it.
+	infos := ring.Keys()
+	if len(infos) != 2 || infos[0].ID != "k3" || infos[1].ID != "k2" {
+		t.Errorf("expected the active and the unexpired grace key, got %+v", infos)
+	}
+}
+
+func TestKeyRingAddGraceKey(t *testing.T) {
+	keys := generateKeys(t, 2)
+	ring := NewKeyRing("", keys[1])
+	if id, _ := ring.Active(); id != KeyID(&keys[1].PublicKey) {
+		t.Errorf("expected the key ID as default ID, got %s", id)
+	}
+	if err := ring.AddGraceKey("", keys[0], time.Time{}); err == nil {
+		t.Error("expected an error for a grace key without expiry")
+	}
+	if err := ring.AddGraceKey("", keys[0], time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var v Vote
+	id, err := v.DecodeV1KeyRing(encodeV1(t, keys[0]), ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != KeyID(&keys[0].PublicKey) {
+		t.Errorf("unexpected key ID %s", id)
+	}
+}
+
+func TestServerKeyRing(t *testing.T) {
+	keys := generateKeys(t, 2)
+	ring := NewKeyRing("old", keys[0])
+	if err := ring.Rotate("new", keys[1], time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	infos := make(chan *VoteInfo, 1)
+	server := Server{
+		VoteInfoHandler: func(v *Vote, info *VoteInfo) error {
+			infos <- info
+			return nil
+		},
+		Records: []ReceiverRecord{{KeyRing: ring}},
+	}
+	go server.Serve(listener) //nolint:errcheck
+
+	if server.Records[0].ActiveKey() != keys[1] {
+		t.Error("expected the record's active key to be the ring's")
+	}
+	for i, wantID := range []string{"old", "new"} {
+		client := NewV1Client(listener.Addr().String(), &keys[i].PublicKey)
+		if err = client.SendVote(Vote{ServiceName: "golang", Username: "golang"}); err != nil {
+			t.Fatal(err)
+		}
+		if info := <-infos; info.KeyID != wantID || info.Protocol != V1 {
+			t.Errorf("expected v1 vote decrypted by %s, got %+v", wantID, info)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package votifier
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EncodePublicKey encodes the key as base64 of its X.509 (PKIX) form,
+// the format vote sites and NuVotifier's public.key file use.
+func EncodePublicKey(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// ParsePublicKey parses a key encoded with EncodePublicKey.
+// Whitespace such as line breaks is ignored.
+func ParsePublicKey(encoded string) (*rsa.PublicKey, error) {
+	der, err := decodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+// EncodePrivateKey encodes the key as base64 of its PKCS #8 form,
+// the format of NuVotifier's private.key file.
+func EncodePrivateKey(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// ParsePrivateKey parses a key encoded with EncodePrivateKey.
+// Whitespace such as line breaks is ignored.
+func ParsePrivateKey(encoded string) (*rsa.PrivateKey, error) {
+	der, err := decodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return rsaKey, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	encoded = strings.Join(strings.Fields(encoded), "")
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding key: %w", err)
+	}
+	return der, nil
+}
+
+// Names of the key files in a NuVotifier rsa directory.
+const (
+	PublicKeyFile  = "public.key"
+	PrivateKeyFile = "private.key"
+)
+
+// SaveKeyPair writes the key to public.key and private.key in dir, like
+// NuVotifier stores its keys, creating dir if needed.
+func SaveKeyPair(dir string, key *rsa.PrivateKey) error {
+	pub, err := EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+	priv, err := EncodePrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(dir, PublicKeyFile), []byte(pub), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, PrivateKeyFile), []byte(priv), 0o600)
+}
+
+// LoadKeyPair reads the private key from a directory written by
+// SaveKeyPair or NuVotifier.
+func LoadKeyPair(dir string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(filepath.Join(dir, PrivateKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(string(data))
+}
@@ -0,0 +1,68 @@
+package votifier
+
+import (
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(new(badRandomReader), 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPub, err := ParsePublicKey(pub[:64] + "\n" + pub[64:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsedPub.Equal(&key.PublicKey) {
+		t.Error("public keys don't match")
+	}
+
+	priv, err := EncodePrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPriv, err := ParsePrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsedPriv.Equal(key) {
+		t.Error("private keys don't match")
+	}
+
+	if _, err = ParsePublicKey(priv); err == nil {
+		t.Error("expected error parsing private key as public key")
+	}
+}
+
+func TestKeyPairFiles(t *testing.T) {
+	key, err := rsa.GenerateKey(new(badRandomReader), 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "rsa")
+	if err = SaveKeyPair(dir, key); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadKeyPair(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(key) {
+		t.Error("keys don't match")
+	}
+	pub, err := os.ReadFile(filepath.Join(dir, PublicKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed, err := ParsePublicKey(string(pub)); err != nil || !parsed.Equal(&key.PublicKey) {
+		t.Errorf("unexpected public key file: %v", err)
+	}
+}
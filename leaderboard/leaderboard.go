@@ -0,0 +1,429 @@
+// Package leaderboard aggregates accepted votes into per-period vote counts
+// for top voter lists, such as the monthly top voters shown on a website.
+package leaderboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/internal/fsutil"
+)
+
+// Period is a time span votes are counted in.
+type Period int
+
+// Periods supported by a Board.
+const (
+	Daily Period = iota
+	Weekly
+	Monthly
+	AllTime
+)
+
+func (p Period) String() string {
+	switch p {
+	case Daily:
+		return "daily"
+	case Weekly:
+		return "weekly"
+	case Monthly:
+		return "monthly"
+	case AllTime:
+		return "all-time"
+	}
+	return "unknown"
+}
+
+// Options configure the period boundaries of a Board.
+type Options struct {
+	Location  *time.Location // Time zone periods start in, defaults to UTC.
+	WeekStart time.Weekday   // First day of a week, defaults to Sunday.
+	// How long daily, weekly and monthly periods are kept after they ended,
+	// defaults to 400 days. Negative keeps them forever.
+	Retention time.Duration
+}
+
+// DefaultRetention is the default Options.Retention.
+const DefaultRetention = 400 * 24 * time.Hour
+
+// compactEvery is the number of journaled votes after which a persisted
+// board rewrites its snapshot.
+const compactEvery = 1000
+
+var timeNow = time.Now
+
+// Entry is a voter's position on a leaderboard.
+type Entry struct {
+	Username string
+	Votes    int
+	Rank     int // 1-based, voters with the same number of votes share a rank.
+}
+
+// counts are the votes of a single user in a period.
+type counts struct {
+	Username string         `json:"username"` // Most recently used spelling of the username.
+	Votes    int            `json:"votes"`
+	Services map[string]int `json:"services"`
+}
+
+// snapshot is the file format of a persisted board.
+type snapshot struct {
+	Seq     uint64                        `json:"seq"` // Last journaled vote included in the periods.
+	Periods map[string]map[string]*counts `json:"periods"`
+}
+
+// journalEntry is a vote appended to the journal of a persisted board.
+type journalEntry struct {
+	Seq       uint64 `json:"seq"`
+	Username  string `json:"username"`
+	Service   string `json:"service"`
+	Timestamp int64  `json:"timestamp"` // Unix milliseconds
+}
+
+// Board counts votes per username and service for daily, weekly, monthly
+// and all-time periods.
+type Board struct {
+	opts Options
+	path string
+
+	mu       sync.RWMutex
+	periods  map[string]map[string]*counts // period key -> lower-cased username -> counts
+	journal  *os.File
+	seq      uint64 // last journaled vote
+	journals int    // votes in the journal
+	partial  bool   // a failed write may have left an unterminated entry
+}
+
+// New returns an empty in-memory board.
+func New(opts Options) *Board {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Retention == 0 {
+		opts.Retention = DefaultRetention
+	}
+	return &Board{opts: opts, periods: map[string]map[string]*counts{}}
+}
+
+// Open returns a board persisted at path, loading counts saved by a previous run.
+//
+// Added votes are appended to a journal next to the file, path+".journal",
+// which is merged into the file every 1000 votes and on Close. Expired
+// periods are dropped when the file is written.
+func Open(path string, opts Options) (*Board, error) {
+	b := New(opts)
+	b.path = path
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading leaderboard: %w", err)
+	}
+	if err == nil {
+		if err = b.decode(data); err != nil {
+			return nil, fmt.Errorf("error decoding leaderboard: %w", err)
+		}
+	}
+	if b.journal, err = os.OpenFile(path+".journal", os.O_RDWR|os.O_CREATE, 0o600); err != nil {
+		return nil, fmt.Errorf("error opening leaderboard journal: %w", err)
+	}
+	if err = b.replay(); err != nil {
+		b.journal.Close()
+		return nil, err
+	}
+	b.prune()
+	return b, nil
+}
+
+func (b *Board) decode(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if _, ok := fields["periods"]; ok {
+		var s snapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		b.seq, b.periods = s.Seq, s.Periods
+	} else if err := json.Unmarshal(data, &b.periods); err != nil {
+		// Written by a version without journal.
+		return err
+	}
+	if b.periods == nil {
+		b.periods = map[string]map[string]*counts{}
+	}
+	return nil
+}
+
+// replay counts the votes of the journal not yet in the snapshot and
+// truncates a partially written last entry. Unreadable entries are skipped.
+func (b *Board) replay() error {
+	rd := bufio.NewReader(b.journal)
+	var offset int64
+	for {
+		line, err := rd.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) != 0 {
+				// Incomplete entry from a crash, drop it.
+				if err = b.journal.Truncate(offset); err != nil {
+					return fmt.Errorf("error truncating leaderboard journal: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading leaderboard journal: %w", err)
+		}
+		offset += int64(len(line))
+		b.journals++
+		var e journalEntry
+		if err = json.Unmarshal(line, &e); err != nil {
+			continue
+		}
+		if e.Seq <= b.seq {
+			// Already merged into the snapshot before a crash.
+			continue
+		}
+		b.seq = e.Seq
+		b.count(e.Username, e.Service, time.UnixMilli(e.Timestamp))
+	}
+	_, err := b.journal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// key returns the identifier of the period containing t.
+func (b *Board) key(p Period, t time.Time) string {
+	t = t.In(b.opts.Location)
+	switch p {
+	case Daily:
+		return "day:" + t.Format("2006-01-02")
+	case Weekly:
+		offset := (int(t.Weekday()) - int(b.opts.WeekStart) + 7) % 7
+		return "week:" + t.AddDate(0, 0, -offset).Format("2006-01-02")
+	case Monthly:
+		return "month:" + t.Format("2006-01")
+	}
+	return "all"
+}
+
+// end returns the end of the period with the given key, or false for the
+// all-time period.
+func (b *Board) end(key string) (time.Time, bool) {
+	kind, start, _ := strings.Cut(key, ":")
+	layout := "2006-01-02"
+	if kind == "month" {
+		layout = "2006-01"
+	}
+	t, err := time.ParseInLocation(layout, start, b.opts.Location)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch kind {
+	case "day":
+		return t.AddDate(0, 0, 1), true
+	case "week":
+		return t.AddDate(0, 0, 7), true
+	case "month":
+		return t.AddDate(0, 1, 0), true
+	}
+	return time.Time{}, false
+}
+
+// prune drops the periods that ended longer than the retention ago.
+func (b *Board) prune() {
+	if b.opts.Retention < 0 {
+		return
+	}
+	cutoff := timeNow().Add(-b.opts.Retention)
+	for key := range b.periods {
+		if end, ok := b.end(key); ok && end.Before(cutoff) {
+			delete(b.periods, key)
+		}
+	}
+}
+
+// Add counts the vote in all periods containing the vote's timestamp.
+func (b *Board) Add(vote *votifier.Vote) error {
+	at := vote.Timestamp
+	if at.IsZero() {
+		at = timeNow()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.path == "" {
+		b.count(vote.Username, vote.ServiceName, at)
+		return nil
+	}
+	if b.journal == nil {
+		return os.ErrClosed
+	}
+	line, err := json.Marshal(journalEntry{
+		Seq:       b.seq + 1,
+		Username:  vote.Username,
+		Service:   vote.ServiceName,
+		Timestamp: at.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if b.partial {
+		// Terminate what the failed write left behind.
+		line = append([]byte{'\n'}, line...)
+	}
+	if _, err = b.journal.Write(line); err != nil {
+		b.partial = true
+		return fmt.Errorf("error writing leaderboard journal: %w", err)
+	}
+	b.partial = false
+	b.seq++
+	b.journals++
+	b.count(vote.Username, vote.ServiceName, at)
+	if b.journals >= compactEvery {
+		// The vote is journaled, so a failed compaction doesn't fail it.
+		// It is retried with the next vote and on Close.
+		_ = b.compact()
+	}
+	return nil
+}
+
+// count counts a vote in all periods containing at.
+func (b *Board) count(username, service string, at time.Time) {
+	user := strings.ToLower(username)
+	for _, p := range []Period{Daily, Weekly, Monthly, AllTime} {
+		key := b.key(p, at)
+		users := b.periods[key]
+		if users == nil {
+			users = map[string]*counts{}
+			b.periods[key] = users
+		}
+		c := users[user]
+		if c == nil {
+			c = &counts{Services: map[string]int{}}
+			users[user] = c
+		}
+		c.Username = username
+		c.Votes++
+		c.Services[service]++
+	}
+}
+
+// compact drops expired periods, writes the snapshot and empties the journal.
+func (b *Board) compact() error {
+	b.prune()
+	data, err := json.Marshal(snapshot{Seq: b.seq, Periods: b.periods})
+	if err != nil {
+		return err
+	}
+	if err = fsutil.WriteFileAtomic(b.path, data, 0o600); err != nil {
+		return fmt.Errorf("error writing leaderboard: %w", err)
+	}
+	// Entries left behind by a failed truncate are skipped by their seq.
+	if err = b.journal.Truncate(0); err == nil {
+		_, err = b.journal.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return fmt.Errorf("error truncating leaderboard journal: %w", err)
+	}
+	b.journals = 0
+	return nil
+}
+
+// Close merges the journal into the file of a persisted board and closes it.
+func (b *Board) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.journal == nil {
+		return nil
+	}
+	err := b.compact()
+	if closeErr := b.journal.Close(); err == nil {
+		err = closeErr
+	}
+	b.journal = nil
+	return err
+}
+
+// ranking returns all entries of the period containing at, best first.
+// If service is not empty, only votes from that service are counted.
+func (b *Board) ranking(p Period, at time.Time, service string) []Entry {
+	b.mu.RLock()
+	users := b.periods[b.key(p, at)]
+	entries := make([]Entry, 0, len(users))
+	for _, c := range users {
+		votes := c.Votes
+		if service != "" {
+			votes = c.Services[service]
+		}
+		if votes > 0 {
+			entries = append(entries, Entry{Username: c.Username, Votes: votes})
+		}
+	}
+	b.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Votes != entries[j].Votes {
+			return entries[i].Votes > entries[j].Votes
+		}
+		return strings.ToLower(entries[i].Username) < strings.ToLower(entries[j].Username)
+	})
+	for i := range entries {
+		if i > 0 && entries[i].Votes == entries[i-1].Votes {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+	return entries
+}
+
+// Top returns the n voters with the most votes in the period containing at.
+// If service is not empty, only votes from that service are counted.
+func (b *Board) Top(p Period, at time.Time, service string, n int) []Entry {
+	entries := b.ranking(p, at, service)
+	if n >= 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Rank returns the position of a voter in the period containing at.
+// The returned entry has a zero Rank if the user did not vote in the period.
+func (b *Board) Rank(username string, p Period, at time.Time, service string) Entry {
+	for _, e := range b.ranking(p, at, service) {
+		if strings.EqualFold(e.Username, username) {
+			return e
+		}
+	}
+	return Entry{Username: username}
+}
+
+// Count returns the number of votes of a user in the period containing at.
+func (b *Board) Count(username string, p Period, at time.Time) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if c := b.periods[b.key(p, at)][strings.ToLower(username)]; c != nil {
+		return c.Votes
+	}
+	return 0
+}
+
+// Listener returns a listener counting every vote once next handled it,
+// so a vote resent after next failed isn't counted twice.
+func (b *Board) Listener(next votifier.VoteListener) votifier.VoteListener {
+	return func(vote *votifier.Vote, protocol votifier.Protocol) error {
+		if err := next(vote, protocol); err != nil {
+			return err
+		}
+		return b.Add(vote)
+	}
+}
@@ -0,0 +1,193 @@
+package leaderboard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+func TestBoard(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	// 2024-01-31 23:30 UTC is already February 1st in Berlin.
+	feb := time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC)
+	jan := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	setNow(t, feb)
+
+	path := filepath.Join(t.TempDir(), "leaderboard.json")
+	b, err := Open(path, Options{Location: berlin, WeekStart: time.Monday})
+	if err != nil {
+		t.Fatal(err)
+	}
+	votes := []votifier.Vote{
+		{Username: "alice", ServiceName: "site-a", Timestamp: feb},
+		{Username: "Alice", ServiceName: "site-b", Timestamp: feb},
+		{Username: "bob", ServiceName: "site-a", Timestamp: feb},
+		{Username: "carol", ServiceName: "site-a", Timestamp: feb},
+		{Username: "dave", ServiceName: "site-a", Timestamp: jan},
+	}
+	for i := range votes {
+		if err = b.Add(&votes[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reopen without closing to verify votes are journaled.
+	b, err = Open(path, Options{Location: berlin, WeekStart: time.Monday})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top := b.Top(Monthly, feb, "", 10)
+	if len(top) != 3 || top[0].Username != "Alice" || top[0].Votes != 2 || top[0].Rank != 1 {
+		t.Fatalf("unexpected monthly top %+v", top)
+	}
+	if top[1].Rank != 2 || top[2].Rank != 2 {
+		t.Errorf("expected tied voters to share rank 2, got %+v", top)
+	}
+	if got := b.Top(Monthly, jan, "", 10); len(got) != 1 || got[0].Username != "dave" {
+		t.Errorf("unexpected january top %+v", got)
+	}
+	// Wednesday Jan 31 and Thursday Feb 1 are in the same week starting on Monday.
+	if got := b.Top(Weekly, feb, "", 1); len(got) != 1 || got[0].Username != "Alice" {
+		t.Errorf("unexpected weekly top %+v", got)
+	}
+	if got := b.Count("dave", Weekly, feb); got != 1 {
+		t.Errorf("expected dave's vote in the same week, got %d", got)
+	}
+	if got := b.Top(AllTime, time.Time{}, "site-a", 10); len(got) != 4 || got[0].Rank != 1 || got[3].Rank != 1 {
+		t.Errorf("expected four tied site-a voters, got %+v", got)
+	}
+	if e := b.Rank("BOB", Daily, feb, ""); e.Rank != 2 || e.Votes != 1 {
+		t.Errorf("unexpected rank for bob %+v", e)
+	}
+	if e := b.Rank("dave", Daily, feb, ""); e.Rank != 0 {
+		t.Errorf("expected no rank for dave on feb 1st, got %+v", e)
+	}
+}
+
+func setNow(t *testing.T, now time.Time) {
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+}
+
+func TestBoardRetention(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	setNow(t, now)
+	path := filepath.Join(t.TempDir(), "leaderboard.json")
+	b, err := Open(path, Options{Retention: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{old, now} {
+		if err = b.Add(&votifier.Vote{Username: "alice", ServiceName: "site", Timestamp: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path + ".journal"); err != nil || fi.Size() != 0 {
+		t.Errorf("expected empty journal after close, got %v, %v", fi, err)
+	}
+
+	b, err = Open(path, Options{Retention: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got := b.Count("alice", Monthly, old); got != 0 {
+		t.Errorf("expected expired month to be dropped, got %d votes", got)
+	}
+	if got := b.Count("alice", Monthly, now); got != 1 {
+		t.Errorf("expected 1 vote in the current month, got %d", got)
+	}
+	if got := b.Count("alice", AllTime, now); got != 2 {
+		t.Errorf("expected all-time votes to be kept, got %d", got)
+	}
+}
+
+func TestBoardSkipsMergedJournal(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	setNow(t, now)
+	path := filepath.Join(t.TempDir(), "leaderboard.json")
+	b, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Add(&votifier.Vote{Username: "alice", Timestamp: now}); err != nil {
+		t.Fatal(err)
+	}
+	journal, err := os.ReadFile(path + ".journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash after the snapshot was written but before the
+	// journal was truncated.
+	if err = os.WriteFile(path+".journal", journal, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	b, err = Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got := b.Count("alice", AllTime, now); got != 1 {
+		t.Errorf("expected merged vote to be counted once, got %d", got)
+	}
+}
+
+func TestBoardSkipsBrokenJournalEntries(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	setNow(t, now)
+	path := filepath.Join(t.TempDir(), "leaderboard.json")
+	// A partial write followed by successful ones.
+	journal := `{"seq":1,"username":"alice","service":"site","timestamp":1710072000000}` + "\n" +
+		`{"seq":2,"user{"seq":3,"username":"bob","service":"site","timestamp":1710072000000}` + "\n" +
+		`{"seq":4,"username":"alice","service":"site","timestamp":1710072000000}` + "\n"
+	if err := os.WriteFile(path+".journal", []byte(journal), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got := b.Count("alice", AllTime, now); got != 2 {
+		t.Errorf("expected 2 votes of alice, got %d", got)
+	}
+}
+
+func TestBoardListener(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	setNow(t, now)
+	b := New(Options{})
+	fail := true
+	vl := b.Listener(func(*votifier.Vote, votifier.Protocol) error {
+		if fail {
+			return os.ErrDeadlineExceeded
+		}
+		return nil
+	})
+	v := &votifier.Vote{Username: "alice", Timestamp: now}
+	if err := vl(v, votifier.V2); err == nil {
+		t.Fatal("expected error")
+	}
+	// The vote site resends the failed vote.
+	fail = false
+	if err := vl(v, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Count("alice", AllTime, now); got != 1 {
+		t.Errorf("expected the vote to be counted once, got %d", got)
+	}
+}
@@ -0,0 +1,109 @@
+package votifier
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListenUnix listens on a Unix domain socket at path, e.g. for a sidecar on
+// the same host. A stale socket file left behind by a crashed process is
+// replaced. If perm is not zero, the socket's permissions are set to it.
+// The socket file is removed when the listener is closed. Clients connect
+// to it with the address "unix:" followed by path.
+func ListenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if perm != 0 {
+		if err = os.Chmod(path, perm); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
+
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// SystemdListener is a socket passed by systemd socket activation.
+type SystemdListener struct {
+	Name string // FileDescriptorName= of the socket unit, defaults to the unit name.
+	net.Listener
+}
+
+// listenFdsStart is the first file descriptor passed by systemd.
+var listenFdsStart = 3
+
+// SystemdListeners returns the sockets passed to the process by systemd
+// socket activation, allowing it to serve on ports bound by the init system
+// without privileges. It returns no listeners if the process was not socket
+// activated. The LISTEN_* environment variables are unset so child
+// processes don't inherit them.
+func SystemdListeners() ([]SystemdListener, error) {
+	pid, fds, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid == "" || fds == "" {
+		return nil, nil
+	}
+	if pid != strconv.Itoa(os.Getpid()) {
+		// The sockets were passed to another process.
+		return nil, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	var fdNames []string
+	if names != "" {
+		fdNames = strings.Split(names, ":")
+	}
+
+	listeners := make([]SystemdListener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(fdNames) && fdNames[i] != "" {
+			name = fdNames[i]
+		}
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		// FileListener duplicates the file descriptor, close the inherited one.
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket %s (fd %d) is not a listener: %w", name, fd, err)
+		}
+		listeners = append(listeners, SystemdListener{Name: name, Listener: ln})
+	}
+	return listeners, nil
+}
@@ -0,0 +1,105 @@
+package votifier
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// passFds duplicates the listeners' file descriptors to consecutive numbers
+// starting at listenFdsStart, as systemd would.
+func passFds(t *testing.T, lns ...net.Listener) {
+	t.Helper()
+	start := listenFdsStart
+	listenFdsStart = 500
+	t.Cleanup(func() { listenFdsStart = start })
+	for i, ln := range lns {
+		f, err := ln.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = syscall.Dup3(int(f.Fd()), listenFdsStart+i, 0)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", strconv.Itoa(len(lns)))
+}
+
+func TestSystemdListeners(t *testing.T) {
+	var lns []net.Listener
+	for i := 0; i < 2; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		lns = append(lns, ln)
+	}
+	passFds(t, lns...)
+	t.Setenv("LISTEN_FDNAMES", "votifier")
+
+	listeners, err := SystemdListeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+	if listeners[0].Name != "votifier" || listeners[1].Name != "LISTEN_FD_501" {
+		t.Errorf("unexpected names %q, %q", listeners[0].Name, listeners[1].Name)
+	}
+	for _, env := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		if _, ok := os.LookupEnv(env); ok {
+			t.Errorf("expected %s to be unset", env)
+		}
+	}
+
+	votes := make(chan string, 1)
+	server := &Server{
+		VoteInfoHandler: func(v *Vote, info *VoteInfo) error {
+			votes <- info.Listener
+			return nil
+		},
+		Records: []ReceiverRecord{{TokenProvider: StaticTokenProvider("abcxyz")}},
+	}
+	for _, l := range listeners {
+		server.AddListener(l.Name, l)
+	}
+	go server.ServeAll() //nolint:errcheck
+	defer server.Close()
+
+	for i, ln := range lns {
+		client := NewV2Client(ln.Addr().String(), "abcxyz")
+		if err = client.SendVote(Vote{ServiceName: "golang", Username: "golang"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := <-votes; got != listeners[i].Name {
+			t.Errorf("expected vote on %q, got %q", listeners[i].Name, got)
+		}
+	}
+}
+
+func TestSystemdListenersNotAListener(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	start := listenFdsStart
+	listenFdsStart = 500
+	defer func() { listenFdsStart = start }()
+	if err = syscall.Dup3(int(f.Fd()), listenFdsStart, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+
+	if _, err = SystemdListeners(); err == nil {
+		t.Error("expected an error for a file that is not a socket")
+	}
+}
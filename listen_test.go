@@ -0,0 +1,90 @@
+package votifier
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "votifier.sock")
+	ln, err := ListenUnix(path, 0o660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o660 {
+		t.Errorf("expected permissions 0660, got %o", perm)
+	}
+
+	infos := make(chan *VoteInfo, 1)
+	server := &Server{
+		VoteInfoHandler: func(v *Vote, info *VoteInfo) error {
+			infos <- info
+			return nil
+		},
+		Records: []ReceiverRecord{{TokenProvider: StaticTokenProvider("abcxyz")}},
+	}
+	server.AddListener("sidecar", ln)
+	go server.ServeAll() //nolint:errcheck
+
+	client := NewV2Client("unix:"+path, "abcxyz")
+	if err = client.SendVote(Vote{ServiceName: "golang", Username: "golang"}); err != nil {
+		t.Fatal(err)
+	}
+	if info := <-infos; info.Listener != "sidecar" {
+		t.Errorf("expected vote on the sidecar listener, got %q", info.Listener)
+	}
+
+	if _, err = ListenUnix(path, 0); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("expected a socket in use error, got %v", err)
+	}
+	server.Close()
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed on close, got %v", err)
+	}
+}
+
+func TestListenUnixStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "votifier.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave the socket file behind like a crashed process would.
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	ln, err = ListenUnix(path, 0)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced: %v", err)
+	}
+	ln.Close()
+}
+
+func TestListenUnixNotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "votifier.sock")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix(path, 0); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("expected a not a socket error, got %v", err)
+	}
+}
+
+func TestSystemdListenersNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err := SystemdListeners()
+	if err != nil || len(listeners) != 0 {
+		t.Errorf("expected no listeners for another process, got %v, %v", listeners, err)
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("expected LISTEN_FDS to be unset")
+	}
+}
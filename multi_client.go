@@ -0,0 +1,84 @@
+package votifier
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of votes a MultiClient sends in parallel
+// if no concurrency is configured.
+const DefaultConcurrency = 8
+
+// Target is a destination a MultiClient delivers votes to.
+type Target struct {
+	Name   string // Identifies the target in results, e.g. "lobby-1".
+	Client Client // Client configured with the target's address, protocol and credentials.
+}
+
+// TargetResult is the outcome of delivering a vote to a single target.
+type TargetResult struct {
+	Target   Target
+	Err      error         // Nil if the vote was delivered.
+	Duration time.Duration // How long the delivery took.
+}
+
+// MultiClient sends each vote to a set of targets concurrently.
+//
+// Each target uses its own Client, so targets can mix protocols and credentials:
+//
+//	m := &votifier.MultiClient{Targets: []votifier.Target{
+//		{Name: "lobby-1", Client: votifier.NewV2Client("lobby-1:8192", token)},
+//		{Name: "legacy", Client: votifier.NewV1Client("legacy:8192", publicKey)},
+//	}}
+type MultiClient struct {
+	Targets     []Target
+	Concurrency int // Maximum number of parallel sends, DefaultConcurrency if <= 0.
+}
+
+// Send delivers the vote to all targets and returns one result per target
+// in the order of Targets.
+func (m *MultiClient) Send(vote Vote) []TargetResult {
+	if vote.Timestamp.IsZero() {
+		// Make sure all targets receive the same timestamp.
+		vote.Timestamp = timeNow()
+	}
+
+	concurrency := m.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+
+	results := make([]TargetResult, len(m.Targets))
+	var wg sync.WaitGroup
+	for i, target := range m.Targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target Target) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start := timeNow()
+			err := target.Client.SendVote(vote)
+			results[i] = TargetResult{
+				Target:   target,
+				Err:      err,
+				Duration: timeNow().Sub(start),
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+// FailedResults returns the results of targets the vote could not be delivered to.
+func FailedResults(results []TargetResult) []TargetResult {
+	var failed []TargetResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
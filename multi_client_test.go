@@ -0,0 +1,60 @@
+package votifier
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMultiClient(t *testing.T) {
+	var running, maxRunning int32
+	errDown := errors.New("target down")
+
+	var targets []Target
+	for i := 0; i < 10; i++ {
+		i := i
+		targets = append(targets, Target{
+			Name: fmt.Sprintf("target-%d", i),
+			Client: ClientFunc(func(vote Vote) error {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				if vote.Timestamp.IsZero() {
+					return errors.New("missing timestamp")
+				}
+				if i%3 == 0 {
+					return errDown
+				}
+				return nil
+			}),
+		})
+	}
+
+	m := &MultiClient{Targets: targets, Concurrency: 3}
+	results := m.Send(Vote{ServiceName: "golang", Username: "golang"})
+	if len(results) != len(targets) {
+		t.Fatalf("expected %d results, got %d", len(targets), len(results))
+	}
+	for i, r := range results {
+		if r.Target.Name != targets[i].Name {
+			t.Errorf("result %d is for %s, expected %s", i, r.Target.Name, targets[i].Name)
+		}
+		if wantErr := i%3 == 0; wantErr != errors.Is(r.Err, errDown) {
+			t.Errorf("unexpected error for %s: %v", r.Target.Name, r.Err)
+		}
+	}
+	if got := len(FailedResults(results)); got != 4 {
+		t.Errorf("expected 4 failed results, got %d", got)
+	}
+	if maxRunning > 3 {
+		t.Errorf("expected at most 3 concurrent sends, got %d", maxRunning)
+	}
+}
@@ -0,0 +1,234 @@
+package votifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.minekube.com/votifier/internal/fsutil"
+)
+
+// PendingVote is a vote waiting for its player to come online.
+type PendingVote struct {
+	Vote     Vote      `json:"vote"`
+	Protocol Protocol  `json:"protocol"`
+	Received time.Time `json:"received"`
+}
+
+// PendingVoteStore stores votes of offline players until they are claimed.
+// Usernames are matched case-insensitively, like Minecraft usernames.
+type PendingVoteStore interface {
+	// Add stores a pending vote for the vote's username.
+	Add(vote PendingVote) error
+	// Claim removes and returns the unexpired pending votes of a player,
+	// oldest first. It is typically called when the player joins.
+	Claim(username string) ([]PendingVote, error)
+	// Count returns the number of unexpired pending votes of a player.
+	Count(username string) (int, error)
+}
+
+// PendingOptions configures the limits of a PendingVoteStore.
+type PendingOptions struct {
+	TTL          time.Duration // Pending votes older than this are dropped, zero keeps them forever.
+	MaxPerPlayer int           // If exceeded, the oldest pending votes are dropped, zero means unlimited.
+}
+
+// MemoryPendingStore is a PendingVoteStore keeping votes in memory.
+type MemoryPendingStore struct {
+	opts PendingOptions
+
+	mu    sync.Mutex
+	votes map[string][]PendingVote
+}
+
+var _ PendingVoteStore = (*MemoryPendingStore)(nil)
+
+// NewMemoryPendingStore returns an empty in-memory store.
+func NewMemoryPendingStore(opts PendingOptions) *MemoryPendingStore {
+	return &MemoryPendingStore{opts: opts, votes: map[string][]PendingVote{}}
+}
+
+func pendingKey(username string) string {
+	return strings.ToLower(username)
+}
+
+// Add implements PendingVoteStore.
+func (s *MemoryPendingStore) Add(vote PendingVote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(vote)
+	return nil
+}
+
+func (s *MemoryPendingStore) add(vote PendingVote) {
+	if vote.Received.IsZero() {
+		vote.Received = timeNow()
+	}
+	key := pendingKey(vote.Vote.Username)
+	votes := s.unexpired(key)
+	// Keep the votes ordered by the time they were received.
+	i := sort.Search(len(votes), func(i int) bool { return votes[i].Received.After(vote.Received) })
+	votes = append(votes, PendingVote{})
+	copy(votes[i+1:], votes[i:])
+	votes[i] = vote
+	if limit := s.opts.MaxPerPlayer; limit > 0 && len(votes) > limit {
+		votes = votes[len(votes)-limit:]
+	}
+	s.votes[key] = votes
+}
+
+// unexpired drops expired votes of the player and returns the remaining ones.
+func (s *MemoryPendingStore) unexpired(key string) []PendingVote {
+	votes := s.votes[key]
+	if s.opts.TTL > 0 {
+		cutoff := timeNow().Add(-s.opts.TTL)
+		kept := votes[:0]
+		for _, v := range votes {
+			if !v.Received.Before(cutoff) {
+				kept = append(kept, v)
+			}
+		}
+		votes = kept
+	}
+	if len(votes) == 0 {
+		delete(s.votes, key)
+		return nil
+	}
+	s.votes[key] = votes
+	return votes
+}
+
+// prune drops the expired votes of all players.
+func (s *MemoryPendingStore) prune() {
+	if s.opts.TTL <= 0 {
+		return
+	}
+	for key := range s.votes {
+		s.unexpired(key)
+	}
+}
+
+// Claim implements PendingVoteStore.
+func (s *MemoryPendingStore) Claim(username string) ([]PendingVote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.claim(username), nil
+}
+
+func (s *MemoryPendingStore) claim(username string) []PendingVote {
+	key := pendingKey(username)
+	votes := s.unexpired(key)
+	delete(s.votes, key)
+	return votes
+}
+
+// Count implements PendingVoteStore.
+func (s *MemoryPendingStore) Count(username string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.unexpired(pendingKey(username))), nil
+}
+
+// FilePendingStore is a PendingVoteStore persisting votes to a JSON file.
+// The file is rewritten atomically on every change.
+type FilePendingStore struct {
+	path string
+	mem  *MemoryPendingStore
+}
+
+var _ PendingVoteStore = (*FilePendingStore)(nil)
+
+// NewFilePendingStore opens the store at path, loading votes stored by a previous run.
+func NewFilePendingStore(path string, opts PendingOptions) (*FilePendingStore, error) {
+	s := &FilePendingStore{path: path, mem: NewMemoryPendingStore(opts)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading pending votes: %w", err)
+	}
+	if err = json.Unmarshal(data, &s.mem.votes); err != nil {
+		return nil, fmt.Errorf("error decoding pending votes: %w", err)
+	}
+	if s.mem.votes == nil {
+		s.mem.votes = map[string][]PendingVote{}
+	}
+	for _, votes := range s.mem.votes {
+		sort.SliceStable(votes, func(i, j int) bool { return votes[i].Received.Before(votes[j].Received) })
+	}
+	// Drop votes that expired while the store was closed.
+	s.mem.prune()
+	return s, nil
+}
+
+// Add implements PendingVoteStore.
+func (s *FilePendingStore) Add(vote PendingVote) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	key := pendingKey(vote.Vote.Username)
+	prev, ok := s.mem.votes[key]
+	prev = append([]PendingVote(nil), prev...)
+	s.mem.add(vote)
+	if err := s.save(); err != nil {
+		// Roll back, the vote was not stored.
+		if ok {
+			s.mem.votes[key] = prev
+		} else {
+			delete(s.mem.votes, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// Claim implements PendingVoteStore.
+func (s *FilePendingStore) Claim(username string) ([]PendingVote, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	votes := s.mem.claim(username)
+	if len(votes) == 0 {
+		return nil, nil
+	}
+	if err := s.save(); err != nil {
+		// Keep the votes so they can be claimed again.
+		s.mem.votes[pendingKey(username)] = votes
+		return nil, err
+	}
+	return votes, nil
+}
+
+// Count implements PendingVoteStore.
+func (s *FilePendingStore) Count(username string) (int, error) {
+	return s.mem.Count(username)
+}
+
+func (s *FilePendingStore) save() error {
+	// Players that never return would otherwise keep their expired votes forever.
+	s.mem.prune()
+	data, err := json.Marshal(s.mem.votes)
+	if err != nil {
+		return err
+	}
+	if err = fsutil.WriteFileAtomic(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("error writing pending votes: %w", err)
+	}
+	return nil
+}
+
+// OfflineVoteListener returns a listener that passes votes of online players
+// to handler and stores votes of offline players in the store, to be claimed
+// when they join.
+func OfflineVoteListener(store PendingVoteStore, online func(username string) bool, handler VoteListener) VoteListener {
+	return func(vote *Vote, protocol Protocol) error {
+		if online(vote.Username) {
+			return handler(vote, protocol)
+		}
+		return store.Add(PendingVote{Vote: *vote, Protocol: protocol})
+	}
+}
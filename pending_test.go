@@ -0,0 +1,170 @@
+package votifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPendingVoteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+	opts := PendingOptions{TTL: time.Hour, MaxPerPlayer: 2}
+	file, err := NewFilePendingStore(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]PendingVoteStore{
+		"memory": NewMemoryPendingStore(opts),
+		"file":   file,
+	} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			add := func(service string, age time.Duration) {
+				err := store.Add(PendingVote{
+					Vote:     Vote{ServiceName: service, Username: "Golang"},
+					Protocol: V2,
+					Received: now.Add(-age),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			add("expired", 2*time.Hour)
+			add("dropped", 3*time.Minute)
+			add("first", 2*time.Minute)
+			add("second", time.Minute)
+
+			if n, _ := store.Count("golang"); n != 2 {
+				t.Errorf("expected 2 pending votes, got %d", n)
+			}
+			votes, err := store.Claim("GOLANG")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(votes) != 2 || votes[0].Vote.ServiceName != "first" || votes[1].Vote.ServiceName != "second" {
+				t.Errorf("unexpected claimed votes %+v", votes)
+			}
+			if votes, _ = store.Claim("golang"); len(votes) != 0 {
+				t.Errorf("expected votes to be claimed once, got %+v", votes)
+			}
+		})
+	}
+
+	// Votes survive reopening the file store.
+	if err = file.Add(PendingVote{Vote: Vote{Username: "golang"}}); err != nil {
+		t.Fatal(err)
+	}
+	file, err = NewFilePendingStore(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := file.Count("golang"); n != 1 {
+		t.Errorf("expected 1 pending vote after reopening, got %d", n)
+	}
+}
+
+func TestPendingVoteStoreUnordered(t *testing.T) {
+	s := NewMemoryPendingStore(PendingOptions{TTL: time.Hour})
+	now := time.Now()
+	for _, v := range []struct {
+		service string
+		age     time.Duration
+	}{{"recent", time.Minute}, {"expired", 2 * time.Hour}, {"older", 2 * time.Minute}} {
+		if err := s.Add(PendingVote{Vote: Vote{ServiceName: v.service, Username: "golang"}, Received: now.Add(-v.age)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	votes, _ := s.Claim("golang")
+	if len(votes) != 2 || votes[0].Vote.ServiceName != "older" || votes[1].Vote.ServiceName != "recent" {
+		t.Errorf("expected unexpired votes oldest first, got %+v", votes)
+	}
+}
+
+func TestFilePendingStoreDropsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+	opts := PendingOptions{TTL: time.Hour}
+	now := time.Now()
+	s, err := NewFilePendingStore(path, PendingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stored without a TTL, so nothing is dropped yet.
+	for _, name := range []string{"gone", "away"} {
+		if err = s.Add(PendingVote{Vote: Vote{Username: name}, Received: now.Add(-2 * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Expired votes of players that never return are dropped on load...
+	if s, err = NewFilePendingStore(path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.mem.votes) != 0 {
+		t.Errorf("expected expired votes to be dropped on load, got %v", s.mem.votes)
+	}
+
+	// ...and on save.
+	stubNow(t, now.Add(-2*time.Hour))
+	if err = s.Add(PendingVote{Vote: Vote{Username: "gone"}}); err != nil {
+		t.Fatal(err)
+	}
+	stubNow(t, now)
+	if err = s.Add(PendingVote{Vote: Vote{Username: "golang"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"gone"`) {
+		t.Errorf("expected expired votes to be dropped on save, got %s", data)
+	}
+}
+
+func TestFilePendingStoreRollback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pending")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFilePendingStore(filepath.Join(dir, "pending.json"), PendingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(PendingVote{Vote: Vote{ServiceName: "kept", Username: "golang"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(PendingVote{Vote: Vote{ServiceName: "failed", Username: "golang"}}); err == nil {
+		t.Fatal("expected save to fail")
+	}
+	if n, _ := s.Count("golang"); n != 1 {
+		t.Errorf("expected failed vote to be rolled back, got %d pending votes", n)
+	}
+}
+
+func TestOfflineVoteListener(t *testing.T) {
+	store := NewMemoryPendingStore(PendingOptions{})
+	var handled []string
+	vl := OfflineVoteListener(store, func(username string) bool { return username == "online" },
+		func(v *Vote, _ Protocol) error {
+			handled = append(handled, v.Username)
+			return nil
+		})
+
+	for _, username := range []string{"online", "offline"} {
+		if err := vl(&Vote{Username: username}, V1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(handled) != 1 || handled[0] != "online" {
+		t.Errorf("expected only online vote to be handled, got %v", handled)
+	}
+	if n, _ := store.Count("offline"); n != 1 {
+		t.Errorf("expected offline vote to be stored, got %d", n)
+	}
+}
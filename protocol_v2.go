@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,62 +22,101 @@ type votifier2Inner struct {
 	ServiceName string `json:"serviceName"`
 	Username    string `json:"username"`
 	Address     string `json:"address"`
-	Timestamp   int64  `json:"timestamp"`
+	Timestamp   millis `json:"timestamp"`
 	Challenge   string `json:"challenge"`
 }
 
+// millis is a Unix timestamp in milliseconds. NuVotifier sends it as a JSON string
+// when forwarding votes, so both numbers and strings are accepted when decoding.
+// Like parseTime, a string that isn't a number falls back to the current time.
+type millis int64
+
+func (m *millis) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			ms = timeNow().UnixMilli()
+		}
+		*m = millis(ms)
+		return nil
+	}
+	return json.Unmarshal(data, (*int64)(m))
+}
+
 const v2Magic int16 = 0x733A
 
+// DecodeV2 decodes the vote from the V2 protocol. The vote's signature is
+// verified with the tokens of its service, see TokensOf.
 func (v *Vote) DecodeV2(data []byte, tokenProvider TokenProvider, challenge string) error {
+	_, err := v.DecodeV2TokenID(data, tokenProvider, challenge)
+	return err
+}
+
+// DecodeV2TokenID is like DecodeV2 but also returns the TokenID of the
+// token that verified the vote's signature.
+func (v *Vote) DecodeV2TokenID(data []byte, tokenProvider TokenProvider, challenge string) (string, error) {
 	rd := bytes.NewReader(data)
 
 	// verify v2 magic
 	var magicRead int16
 	err := binary.Read(rd, binary.BigEndian, &magicRead)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if magicRead != v2Magic {
-		return errors.New("v2 magic mismatch")
+		return "", errors.New("v2 magic mismatch")
 	}
 
 	// read message length
 	var length int16
 	if err = binary.Read(rd, binary.BigEndian, &length); err != nil {
-		return err
+		return "", err
 	}
 
 	// now for the fun part
 	var wrapper votifier2Wrapper
 	if err = json.NewDecoder(rd).Decode(&wrapper); err != nil {
-		return err
+		return "", err
 	}
 
 	var vote votifier2Inner
 	if err = json.NewDecoder(strings.NewReader(wrapper.Payload)).Decode(&vote); err != nil {
-		return err
+		return "", err
 	}
 
 	// validate challenge
 	if vote.Challenge != challenge {
-		return errors.New("invalid challenge")
+		return "", errors.New("invalid challenge")
 	}
 
-	// validate HMAC
-	token := tokenProvider.Token(vote.ServiceName)
-	m := hmac.New(sha256.New, []byte(token))
-	m.Write([]byte(wrapper.Payload))
-	s := m.Sum(nil)
-	if !hmac.Equal(s, wrapper.Signature) {
-		return errors.New("invalid signature")
+	// validate HMAC with any of the service's tokens
+	var tokenID string
+	for _, token := range TokensOf(tokenProvider, vote.ServiceName) {
+		if token == "" {
+			// No token, anyone could sign with an empty key.
+			continue
+		}
+		m := hmac.New(sha256.New, []byte(token))
+		m.Write([]byte(wrapper.Payload))
+		if hmac.Equal(m.Sum(nil), wrapper.Signature) {
+			tokenID = TokenID(token)
+			break
+		}
+	}
+	if tokenID == "" {
+		return "", errors.New("invalid signature")
 	}
 
 	v.ServiceName = vote.ServiceName
 	v.Username = vote.Username
 	v.Address = vote.Address
-	v.Timestamp = time.UnixMilli(vote.Timestamp)
-	return nil
+	v.Timestamp = time.UnixMilli(int64(vote.Timestamp))
+	return tokenID, nil
 }
 
 func (v *Vote) EncodeV2(token string, challenge string) ([]byte, error) {
@@ -87,7 +127,7 @@ func (v *Vote) EncodeV2(token string, challenge string) ([]byte, error) {
 		ServiceName: v.ServiceName,
 		Address:     v.Address,
 		Username:    v.Username,
-		Timestamp:   v.Timestamp.UnixMilli(),
+		Timestamp:   millis(v.Timestamp.UnixMilli()),
 		Challenge:   challenge,
 	}
 
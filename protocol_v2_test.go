@@ -1,6 +1,11 @@
 package votifier
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
 	"reflect"
 	"testing"
 	"time"
@@ -34,3 +39,61 @@ func TestEncodeV2(t *testing.T) {
 		t.Error("votes don't match: ", v, "-", d)
 	}
 }
+
+func TestDecodeV2StringTimestamp(t *testing.T) {
+	now := time.UnixMilli(1800000000000)
+	stubNow(t, now)
+
+	tests := []struct {
+		timestamp string
+		want      int64
+	}{
+		// NuVotifier forwards the timestamp as a string.
+		{timestamp: `"1700000000000"`, want: 1700000000000},
+		{timestamp: `1700000000000`, want: 1700000000000},
+		// Some vote sites send no usable timestamp, fall back to now.
+		{timestamp: `""`, want: now.UnixMilli()},
+		{timestamp: `"2024-01-01 10:00:00"`, want: now.UnixMilli()},
+	}
+	for _, tt := range tests {
+		payload := `{"serviceName":"golang","username":"golang","address":"127.0.0.1","timestamp":` + tt.timestamp + `,"challenge":"xyz"}`
+		m := hmac.New(sha256.New, []byte("abcxyz"))
+		m.Write([]byte(payload))
+		wrapper, err := json.Marshal(votifier2Wrapper{Payload: payload, Signature: m.Sum(nil)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		_ = binary.Write(&buf, binary.BigEndian, v2Magic)
+		_ = binary.Write(&buf, binary.BigEndian, int16(len(wrapper)))
+		buf.Write(wrapper)
+
+		var v Vote
+		if err = v.DecodeV2(buf.Bytes(), StaticTokenProvider("abcxyz"), "xyz"); err != nil {
+			t.Errorf("%s: %v", tt.timestamp, err)
+			continue
+		}
+		if v.Timestamp.UnixMilli() != tt.want {
+			t.Errorf("%s: unexpected timestamp %v", tt.timestamp, v.Timestamp)
+		}
+	}
+}
+
+func TestDecodeV2RejectsEmptyToken(t *testing.T) {
+	providers := map[string]TokenProvider{
+		"static": StaticTokenProvider(""),
+		"func":   TokenProviderFunc(func(string) string { return "" }),
+		"map":    NewMapTokenProvider(map[string]string{"other": "abcxyz"}),
+	}
+	for name, p := range providers {
+		v := Vote{ServiceName: "attacker", Username: "golang"}
+		data, err := v.EncodeV2("", "xyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d Vote
+		if err = d.DecodeV2(data, p, "xyz"); err == nil {
+			t.Errorf("%s: expected vote signed with an empty key to be rejected", name)
+		}
+	}
+}
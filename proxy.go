@@ -0,0 +1,202 @@
+package votifier
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrProxyClosed is returned by the VoteListener of a closed Proxy.
+var ErrProxyClosed = errors.New("proxy is closed")
+
+// ProxyBackend is a server a Proxy re-sends votes to.
+type ProxyBackend struct {
+	Name     string   // Identifies the backend in results, e.g. "lobby-1".
+	Client   Client   // Client configured with the backend's address, protocol and credentials.
+	Services []string // Optional, only votes from these services are sent to the backend.
+}
+
+// accepts reports whether votes from the service are sent to the backend.
+func (b *ProxyBackend) accepts(service string) bool {
+	if len(b.Services) == 0 {
+		return true
+	}
+	for _, s := range b.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Proxy accepts votes like a Server and re-sends them to a list of backends.
+//
+// Votes are acknowledged to the vote site as soon as they are decoded and are
+// forwarded in the background. At most MaxPending votes are forwarded at a
+// time, further votes wait for one of them to finish. Each backend delivery
+// is attempted up to MaxAttempts times; for delivery that survives longer
+// outages and restarts, use a QueueClient as the backend's Client.
+type Proxy struct {
+	Records       []ReceiverRecord
+	Backends      []ProxyBackend
+	MaxAttempts   int                         // Delivery attempts per backend, defaults to 3.
+	RetryInterval time.Duration               // Delay between attempts, defaults to 1 second.
+	Concurrency   int                         // Maximum number of parallel sends per vote, see MultiClient.
+	MaxPending    int                         // Maximum number of votes forwarded in the background, defaults to 100.
+	OnResult      func(*Vote, []TargetResult) // Optional, called after every forwarded vote.
+	OnErr         func(net.Conn, error)       // Optional connection handler
+
+	once    sync.Once
+	pending chan struct{} // semaphore of votes forwarded in the background
+	done    chan struct{} // closed by Close
+	mu      sync.Mutex
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func (p *Proxy) init() {
+	p.once.Do(func() {
+		n := p.MaxPending
+		if n <= 0 {
+			n = 100
+		}
+		p.pending = make(chan struct{}, n)
+		p.done = make(chan struct{})
+	})
+}
+
+// Server returns a Server accepting votes for the proxy.
+func (p *Proxy) Server() *Server {
+	return &Server{
+		VoteHandler: p.VoteListener(),
+		Records:     p.Records,
+		OnErr:       p.OnErr,
+	}
+}
+
+// ListenAndServe binds to a specified address-port pair and starts proxying votes.
+func (p *Proxy) ListenAndServe(address string) error {
+	return p.Server().ListenAndServe(address)
+}
+
+// Serve proxies votes received on the provided listener.
+func (p *Proxy) Serve(ln net.Listener) error {
+	return p.Server().Serve(ln)
+}
+
+// VoteListener returns a listener that forwards votes in the background.
+// It fails with ErrProxyClosed once the proxy is closed.
+func (p *Proxy) VoteListener() VoteListener {
+	return func(vote *Vote, _ Protocol) error {
+		p.init()
+		select {
+		case p.pending <- struct{}{}:
+		case <-p.done:
+			return ErrProxyClosed
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.pending
+			return ErrProxyClosed
+		}
+		p.wg.Add(1)
+		p.mu.Unlock()
+
+		v := *vote
+		go func() {
+			defer func() {
+				<-p.pending
+				p.wg.Done()
+			}()
+			p.Forward(v)
+		}()
+		return nil
+	}
+}
+
+// Wait blocks until all votes received so far have been forwarded.
+func (p *Proxy) Wait() {
+	p.wg.Wait()
+}
+
+// Close stops accepting votes, cancels pending retries and waits for the
+// votes being forwarded.
+func (p *Proxy) Close() error {
+	p.init()
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+	close(p.done)
+	p.wg.Wait()
+	return nil
+}
+
+// Forward sends the vote to all backends accepting its service and
+// returns one result per such backend.
+func (p *Proxy) Forward(vote Vote) []TargetResult {
+	p.init()
+	m := &MultiClient{Concurrency: p.Concurrency}
+	var targets []Target
+	for i := range p.Backends {
+		b := &p.Backends[i]
+		if b.accepts(vote.ServiceName) {
+			targets = append(targets, Target{Name: b.Name, Client: b.Client})
+			m.Targets = append(m.Targets, Target{Name: b.Name, Client: p.retrying(b.Client)})
+		}
+	}
+	results := m.Send(vote)
+	for i := range results {
+		// Report the backend's own client rather than the retry wrapper.
+		results[i].Target = targets[i]
+	}
+	if p.OnResult != nil {
+		p.OnResult(&vote, results)
+	}
+	return results
+}
+
+func (p *Proxy) retrying(client Client) Client {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	interval := p.RetryInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &retryClient{client: client, attempts: attempts, interval: interval, done: p.done}
+}
+
+// retryClient retries failed deliveries a fixed number of times,
+// until done is closed.
+type retryClient struct {
+	client   Client
+	attempts int
+	interval time.Duration
+	done     <-chan struct{}
+}
+
+func (c *retryClient) SendVote(vote Vote) error {
+	var err error
+	for i := 0; i < c.attempts; i++ {
+		if i != 0 {
+			t := time.NewTimer(c.interval)
+			select {
+			case <-t.C:
+			case <-c.done:
+				t.Stop()
+				return err
+			}
+		}
+		if err = c.client.SendVote(vote); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package votifier
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProxy(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]string{}
+	flaky := 2
+	backend := func(name string) Client {
+		return ClientFunc(func(v Vote) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if name == "flaky" && flaky > 0 {
+				flaky--
+				return errors.New("temporarily down")
+			}
+			received[name] = append(received[name], v.ServiceName)
+			return nil
+		})
+	}
+
+	results := make(chan []TargetResult, 2)
+	p := &Proxy{
+		Records: []ReceiverRecord{{TokenProvider: StaticTokenProvider("abcxyz")}},
+		Backends: []ProxyBackend{
+			{Name: "all", Client: backend("all")},
+			{Name: "only-site-a", Client: backend("only-site-a"), Services: []string{"site-a"}},
+			{Name: "flaky", Client: backend("flaky")},
+			{Name: "down", Client: ClientFunc(func(Vote) error { return errors.New("down") })},
+		},
+		RetryInterval: time.Millisecond,
+		OnResult:      func(_ *Vote, r []TargetResult) { results <- r },
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go p.Serve(listener) //nolint:errcheck
+
+	client := NewV2Client(listener.Addr().String(), "abcxyz")
+	for _, service := range []string{"site-a", "site-b"} {
+		if err = client.SendVote(Vote{ServiceName: service, Username: "golang"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-results:
+			for _, res := range r {
+				if wantErr := res.Target.Name == "down"; wantErr != (res.Err != nil) {
+					t.Errorf("unexpected result for %s: %v", res.Target.Name, res.Err)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("votes were not forwarded")
+		}
+	}
+	p.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received["all"]) != 2 || len(received["flaky"]) != 2 {
+		t.Errorf("expected all votes on unfiltered backends, got %v", received)
+	}
+	if got := received["only-site-a"]; len(got) != 1 || got[0] != "site-a" {
+		t.Errorf("expected only site-a votes on filtered backend, got %v", got)
+	}
+}
+
+func TestProxyClose(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	p := &Proxy{
+		Backends: []ProxyBackend{{Name: "down", Client: ClientFunc(func(Vote) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			return errors.New("down")
+		})}},
+		MaxAttempts:   3,
+		RetryInterval: time.Hour,
+		MaxPending:    1,
+	}
+	listener := p.VoteListener()
+	if err := listener(&Vote{}, V2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second vote waits for the first one, which waits for its retry.
+	blocked := make(chan error)
+	go func() { blocked <- listener(&Vote{}, V2) }()
+	select {
+	case err := <-blocked:
+		t.Fatalf("expected vote to wait for a free slot, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	closed := make(chan error)
+	go func() { closed <- p.Close() }()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("close did not cancel the retry")
+	}
+	if err := <-blocked; !errors.Is(err, ErrProxyClosed) {
+		t.Fatalf("expected waiting vote to fail with ErrProxyClosed, got %v", err)
+	}
+	if err := listener(&Vote{}, V2); !errors.Is(err, ErrProxyClosed) {
+		t.Fatalf("expected ErrProxyClosed, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls > 2 {
+		t.Errorf("expected retries to stop on close, got %d calls", calls)
+	}
+}
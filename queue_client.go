@@ -0,0 +1,321 @@
+package votifier
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrQueueClosed is returned when sending a vote through a closed QueueClient.
+var ErrQueueClosed = errors.New("queue closed")
+
+// QueueOptions configures a QueueClient.
+type QueueOptions struct {
+	RetryInterval    time.Duration     // Delay before the first retry, defaults to 5 seconds.
+	MaxRetryInterval time.Duration     // Upper bound of the exponential backoff, defaults to 5 minutes.
+	OnErr            func(Vote, error) // Optional, called for every failed delivery attempt.
+
+	// MaxAttempts is the number of delivery attempts after which a vote is
+	// dropped from the queue, so a vote the target rejects for good doesn't
+	// block the votes queued after it. Zero retries forever.
+	MaxAttempts int
+	// OnDrop is optionally called with the last error of a vote that was
+	// dropped after MaxAttempts.
+	OnDrop func(Vote, error)
+}
+
+// QueueClient is a Client that persists votes to an append-only spool file
+// and delivers them through another Client in the background.
+//
+// Votes are delivered in the order they were queued. Failed deliveries are
+// retried with exponential backoff until they succeed or MaxAttempts is
+// reached, and pending votes
+// survive process restarts since they are replayed from the spool on open.
+type QueueClient struct {
+	client Client
+	opts   QueueOptions
+
+	mu      sync.Mutex
+	spool   *os.File
+	path    string
+	pending []spoolRecord
+	nextID  uint64
+	closed  bool
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// spoolRecord is a line in the spool file. A record either queues a vote
+// or acknowledges the delivery of a previously queued vote.
+type spoolRecord struct {
+	ID     uint64    `json:"id"`
+	Vote   *Vote     `json:"vote,omitempty"`
+	Queued time.Time `json:"queued,omitempty"`
+	Ack    bool      `json:"ack,omitempty"`
+}
+
+// NewQueueClient opens (or creates) the spool file at spoolPath, restores
+// pending votes from it and starts delivering them through client.
+// The options may be nil.
+func NewQueueClient(client Client, spoolPath string, opts *QueueOptions) (*QueueClient, error) {
+	q := &QueueClient{
+		client: client,
+		path:   spoolPath,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.RetryInterval <= 0 {
+		q.opts.RetryInterval = 5 * time.Second
+	}
+	if q.opts.MaxRetryInterval < q.opts.RetryInterval {
+		q.opts.MaxRetryInterval = 5 * time.Minute
+	}
+
+	if err := q.restore(); err != nil {
+		return nil, err
+	}
+	// Rewrite the spool so it only contains pending votes.
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+
+	q.wg.Add(1)
+	go q.run()
+	return q, nil
+}
+
+// restore replays the spool file to rebuild the pending votes.
+func (q *QueueClient) restore() error {
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening spool: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var rec spoolRecord
+		if err = json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			// A partially written record from a crash, skip it.
+			continue
+		}
+		if rec.ID >= q.nextID {
+			q.nextID = rec.ID + 1
+		}
+		if rec.Ack {
+			q.remove(rec.ID)
+		} else if rec.Vote != nil {
+			q.pending = append(q.pending, rec)
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return fmt.Errorf("error reading spool: %w", err)
+	}
+	return nil
+}
+
+// compact atomically replaces the spool with one containing only the pending votes.
+func (q *QueueClient) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("error compacting spool: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, rec := range q.pending {
+		if err = enc.Encode(rec); err != nil {
+			tmp.Close()
+			return fmt.Errorf("error compacting spool: %w", err)
+		}
+	}
+	if err = w.Flush(); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error compacting spool: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), q.path); err != nil {
+		return fmt.Errorf("error compacting spool: %w", err)
+	}
+
+	if q.spool != nil {
+		q.spool.Close()
+	}
+	q.spool, err = os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening spool: %w", err)
+	}
+	return nil
+}
+
+// append writes a record to the spool and syncs it to disk.
+func (q *QueueClient) append(rec spoolRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err = q.spool.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("error writing spool: %w", err)
+	}
+	if err = q.spool.Sync(); err != nil {
+		return fmt.Errorf("error syncing spool: %w", err)
+	}
+	return nil
+}
+
+func (q *QueueClient) remove(id uint64) {
+	for i, rec := range q.pending {
+		if rec.ID == id {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// SendVote queues the vote for delivery. It returns once the vote is
+// persisted to the spool, not when it was delivered.
+func (q *QueueClient) SendVote(vote Vote) error {
+	if vote.Timestamp.IsZero() {
+		vote.Timestamp = timeNow()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	rec := spoolRecord{ID: q.nextID, Vote: &vote, Queued: timeNow()}
+	if err := q.append(rec); err != nil {
+		return err
+	}
+	q.nextID++
+	q.pending = append(q.pending, rec)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Len returns the number of votes waiting for delivery.
+func (q *QueueClient) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// OldestPendingAge returns how long the oldest pending vote has been queued,
+// or zero if the queue is empty.
+func (q *QueueClient) OldestPendingAge() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return 0
+	}
+	return timeNow().Sub(q.pending[0].Queued)
+}
+
+// Close stops delivering votes and closes the spool.
+// Pending votes are delivered after the spool is opened again.
+func (q *QueueClient) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mu.Unlock()
+
+	close(q.done)
+	q.wg.Wait()
+	return q.spool.Close()
+}
+
+func (q *QueueClient) run() {
+	defer q.wg.Done()
+	backoff := q.opts.RetryInterval
+	var attempts int
+	for {
+		q.mu.Lock()
+		var rec spoolRecord
+		ok := len(q.pending) != 0
+		if ok {
+			rec = q.pending[0]
+		}
+		q.mu.Unlock()
+
+		if !ok {
+			select {
+			case <-q.wake:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+
+		if err := q.client.SendVote(*rec.Vote); err != nil {
+			if q.opts.OnErr != nil {
+				q.opts.OnErr(*rec.Vote, err)
+			}
+			if attempts++; q.opts.MaxAttempts > 0 && attempts >= q.opts.MaxAttempts {
+				attempts = 0
+				backoff = q.opts.RetryInterval
+				// Acknowledge the vote so it isn't replayed after a restart.
+				if ackErr := q.ack(rec.ID); ackErr != nil && q.opts.OnErr != nil {
+					q.opts.OnErr(*rec.Vote, ackErr)
+				}
+				if q.opts.OnDrop != nil {
+					q.opts.OnDrop(*rec.Vote, err)
+				}
+				continue
+			}
+			select {
+			case <-time.After(backoff):
+			case <-q.done:
+				return
+			}
+			if backoff *= 2; backoff > q.opts.MaxRetryInterval {
+				backoff = q.opts.MaxRetryInterval
+			}
+			continue
+		}
+		attempts = 0
+		backoff = q.opts.RetryInterval
+
+		if err := q.ack(rec.ID); err != nil && q.opts.OnErr != nil {
+			q.opts.OnErr(*rec.Vote, err)
+		}
+	}
+}
+
+// ack marks a vote as delivered or dropped.
+func (q *QueueClient) ack(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.remove(id)
+	if len(q.pending) == 0 {
+		// Nothing left to replay, start over with an empty spool.
+		return q.compact()
+	}
+	return q.append(spoolRecord{ID: id, Ack: true})
+}
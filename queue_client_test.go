@@ -0,0 +1,136 @@
+package votifier
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueueClient(t *testing.T) {
+	spool := filepath.Join(t.TempDir(), "votes.spool")
+
+	// Queue votes while the target is down.
+	down := ClientFunc(func(Vote) error { return errors.New("target down") })
+	q, err := NewQueueClient(down, spool, &QueueOptions{RetryInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if err = q.SendVote(Vote{ServiceName: "golang", Username: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.Len() != 2 {
+		t.Errorf("expected 2 pending votes, got %d", q.Len())
+	}
+	if q.OldestPendingAge() <= 0 {
+		t.Error("expected positive oldest pending age")
+	}
+	if err = q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.SendVote(Vote{}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+
+	// Simulate a crash in the middle of writing a record.
+	f, err := os.OpenFile(spool, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"id":7,"vote":{"serv`)
+	f.Close()
+
+	// Restart with the target back up.
+	var mu sync.Mutex
+	var delivered []string
+	done := make(chan struct{})
+	up := ClientFunc(func(v Vote) error {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, v.Username)
+		if len(delivered) == 2 {
+			close(done)
+		}
+		return nil
+	})
+	q, err = NewQueueClient(up, spool, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("votes were not delivered")
+	}
+	mu.Lock()
+	if delivered[0] != "alice" || delivered[1] != "bob" {
+		t.Errorf("unexpected delivery order %v", delivered)
+	}
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if q.Len() != 0 || q.OldestPendingAge() != 0 {
+		t.Errorf("expected empty queue, got %d pending", q.Len())
+	}
+}
+
+func TestQueueClientMaxAttempts(t *testing.T) {
+	spool := filepath.Join(t.TempDir(), "votes.spool")
+
+	var mu sync.Mutex
+	var delivered []string
+	dropped := make(chan string, 1)
+	done := make(chan struct{})
+	client := ClientFunc(func(v Vote) error {
+		if v.Username == "alice" {
+			return errors.New("invalid signature")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, v.Username)
+		close(done)
+		return nil
+	})
+	q, err := NewQueueClient(client, spool, &QueueOptions{
+		RetryInterval: time.Millisecond,
+		MaxAttempts:   3,
+		OnDrop:        func(v Vote, _ error) { dropped <- v.Username },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	for _, name := range []string{"alice", "bob"} {
+		if err = q.SendVote(Vote{ServiceName: "golang", Username: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case name := <-dropped:
+		if name != "alice" {
+			t.Errorf("expected alice to be dropped, got %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("vote was not dropped")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("vote after the dropped one was not delivered")
+	}
+	mu.Lock()
+	if len(delivered) != 1 || delivered[0] != "bob" {
+		t.Errorf("unexpected deliveries %v", delivered)
+	}
+	mu.Unlock()
+}
@@ -0,0 +1,231 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/internal/placeholder"
+	"go.minekube.com/votifier/rules"
+)
+
+// Server is a Minecraft server reachable over RCON.
+type Server struct {
+	Name     string // Identifies the server in errors.
+	Address  string // Host and port of the RCON listener.
+	Password string
+}
+
+// ErrInvalidUsername is returned for votes whose username is not a valid
+// Minecraft username, which could otherwise inject arguments or target
+// selectors such as "@a" into commands.
+var ErrInvalidUsername = errors.New("invalid Minecraft username")
+
+// ErrListenerClosed is returned for votes arriving after the Listener was closed.
+var ErrListenerClosed = errors.New("rcon listener closed")
+
+// Listener runs commands on servers when a vote arrives.
+//
+// Commands are templates that may contain the placeholders {username},
+// {service}, {address} and {timestamp}, e.g. "give {username} diamond 1".
+// Votes are rejected with ErrInvalidUsername unless the username consists
+// of 1 to 16 letters, digits and underscores.
+//
+// Commands run in the background once the vote was accepted, so slow or
+// unreachable servers don't keep the vote site waiting past its deadline
+// and make it resend the vote. A command is never sent twice: if a server
+// did not answer a command, it may have run it, so only the following
+// commands are retried.
+type Listener struct {
+	Servers       []Server
+	Commands      []string
+	Timeout       time.Duration                                       // Timeout for connecting and each command, defaults to 5 seconds.
+	MaxAttempts   int                                                 // Attempts per server, defaults to 3.
+	RetryInterval time.Duration                                       // Delay between attempts, defaults to 1 second.
+	MaxPending    int                                                 // Maximum number of votes running commands in the background, defaults to 100.
+	OnErr         func(server Server, vote *votifier.Vote, err error) // Optional, called if a server failed all attempts.
+
+	once    sync.Once
+	pending chan struct{} // semaphore of votes running commands in the background
+	done    chan struct{} // closed by Close
+	mu      sync.Mutex
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func (l *Listener) init() {
+	l.once.Do(func() {
+		n := l.MaxPending
+		if n <= 0 {
+			n = 100
+		}
+		l.pending = make(chan struct{}, n)
+		l.done = make(chan struct{})
+	})
+}
+
+// VoteListener returns a listener passing every vote on to next, if not
+// nil, and running the commands in the background once next succeeded.
+// Failed servers are reported to OnErr and do not fail the vote.
+func (l *Listener) VoteListener(next votifier.VoteListener) votifier.VoteListener {
+	return func(vote *votifier.Vote, protocol votifier.Protocol) error {
+		if !placeholder.ValidUsername(vote.Username) {
+			return fmt.Errorf("%w: %q", ErrInvalidUsername, vote.Username)
+		}
+		if next != nil {
+			if err := next(vote, protocol); err != nil {
+				return err
+			}
+		}
+		commands := make([]string, len(l.Commands))
+		for i, c := range l.Commands {
+			commands[i] = placeholder.Expand(c, vote, nil)
+		}
+		return l.start(*vote, commands)
+	}
+}
+
+// Execute implements rules.Executor for actions of type "command",
+// running the action's "command" parameter on all servers in the
+// background. Failed servers are reported to OnErr.
+func (l *Listener) Execute(vote *votifier.Vote, action rules.Action) error {
+	if action.Type != "command" {
+		return fmt.Errorf("unsupported action type %q", action.Type)
+	}
+	command, ok := action.Params["command"]
+	if !ok {
+		return fmt.Errorf("action of rule %q has no command parameter", action.Rule)
+	}
+	// The rules engine expanded the vote's placeholders in the command.
+	if !placeholder.ValidUsername(vote.Username) {
+		return fmt.Errorf("%w: %q", ErrInvalidUsername, vote.Username)
+	}
+	return l.start(*vote, []string{command})
+}
+
+// start runs the commands for the vote in the background. It blocks while
+// MaxPending votes are running and fails once the listener is closed.
+func (l *Listener) start(vote votifier.Vote, commands []string) error {
+	l.init()
+	select {
+	case l.pending <- struct{}{}:
+	case <-l.done:
+		return ErrListenerClosed
+	}
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		<-l.pending
+		return ErrListenerClosed
+	}
+	l.wg.Add(1)
+	l.mu.Unlock()
+
+	go func() {
+		defer func() {
+			<-l.pending
+			l.wg.Done()
+		}()
+		l.runAll(&vote, commands)
+	}()
+	return nil
+}
+
+// Wait blocks until the commands of all votes received so far have run.
+func (l *Listener) Wait() {
+	l.wg.Wait()
+}
+
+// Close stops accepting votes, cancels pending retries and waits for the
+// commands being run.
+func (l *Listener) Close() error {
+	l.init()
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+	close(l.done)
+	l.wg.Wait()
+	return nil
+}
+
+// runAll runs the commands on all servers concurrently.
+func (l *Listener) runAll(vote *votifier.Vote, commands []string) {
+	var wg sync.WaitGroup
+	for _, s := range l.Servers {
+		wg.Add(1)
+		go func(s Server) {
+			defer wg.Done()
+			if err := l.run(s, commands); err != nil && l.OnErr != nil {
+				l.OnErr(s, vote, err)
+			}
+		}(s)
+	}
+	wg.Wait()
+}
+
+// run runs the commands on a server, retrying from the first command that
+// was not sent.
+func (l *Listener) run(s Server, commands []string) error {
+	attempts := l.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	interval := l.RetryInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	var err, lost error
+	for i := 0; i < attempts && len(commands) != 0; i++ {
+		if i != 0 {
+			t := time.NewTimer(interval)
+			select {
+			case <-t.C:
+			case <-l.done:
+				t.Stop()
+				return fmt.Errorf("listener closed before retrying: %w", err)
+			}
+		}
+		var sent int
+		sent, err = runCommands(s, commands, timeout)
+		if err != nil && sent != 0 {
+			// The server may have run the command, don't run it twice.
+			lost = fmt.Errorf("command %q may not have run: %w", commands[sent-1], err)
+		}
+		commands = commands[sent:]
+		if errors.Is(err, ErrAuthFailed) {
+			// Retrying won't fix a wrong password.
+			break
+		}
+	}
+	if err == nil {
+		err = lost
+	}
+	return err
+}
+
+// runCommands returns how many commands were sent. If it fails after
+// sending a command, that command is the last one sent.
+func runCommands(s Server, commands []string, timeout time.Duration) (int, error) {
+	c, err := Dial(s.Address, s.Password, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	for i, command := range commands {
+		if _, err = c.Command(command); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(commands), nil
+}
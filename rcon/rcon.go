@@ -0,0 +1,168 @@
+// Package rcon implements a Source RCON client as used by Minecraft servers
+// and a vote listener running commands over RCON when a vote arrives.
+package rcon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Packet types of the RCON protocol.
+const (
+	typeResponse = 0
+	typeCommand  = 2
+	typeAuth     = 3
+)
+
+// maxPacketSize is the maximum size of a packet we accept, Minecraft
+// responses are at most 4096 bytes of payload.
+const maxPacketSize = 4096 + 10
+
+// ErrAuthFailed is returned by Dial if the server rejected the password.
+var ErrAuthFailed = errors.New("rcon authentication failed")
+
+// Client is an authenticated RCON connection.
+type Client struct {
+	conn    net.Conn
+	rd      *bufio.Reader
+	timeout time.Duration
+
+	mu     sync.Mutex
+	nextID int32
+}
+
+// Dial connects to an RCON server and authenticates with the password.
+// The timeout applies to connecting and to every command.
+func Dial(address, password string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	c := &Client{conn: conn, rd: bufio.NewReader(conn), timeout: timeout, nextID: 1}
+
+	id, _, err := c.exchange(typeAuth, password)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error authenticating: %w", err)
+	}
+	if id == -1 {
+		conn.Close()
+		return nil, ErrAuthFailed
+	}
+	return c, nil
+}
+
+// Command runs a command on the server and returns its response.
+//
+// Responses longer than a packet are split into several packets by the
+// server. To know when a response is complete, Command sends an empty
+// packet after the command, which the server answers once it answered
+// the command.
+func (c *Client) Command(command string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.deadline(); err != nil {
+		return "", err
+	}
+	id, marker := c.nextID, c.nextID+1
+	c.nextID += 2
+	if err := writePacket(c.conn, id, typeCommand, command); err != nil {
+		return "", fmt.Errorf("error running command: %w", err)
+	}
+	if err := writePacket(c.conn, marker, typeResponse, ""); err != nil {
+		return "", fmt.Errorf("error running command: %w", err)
+	}
+	var body strings.Builder
+	for {
+		respID, _, respBody, err := readPacket(c.rd)
+		if err != nil {
+			return "", fmt.Errorf("error running command: %w", err)
+		}
+		switch respID {
+		case id:
+			body.WriteString(respBody)
+		case marker:
+			return body.String(), nil
+		default:
+			return "", fmt.Errorf("error running command: unexpected response id %d, expected %d", respID, id)
+		}
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// exchange sends a packet and reads the response packet, skipping the empty
+// response value packet some servers send before the auth response.
+func (c *Client) exchange(typ int32, body string) (int32, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.deadline(); err != nil {
+		return 0, "", err
+	}
+
+	id := c.nextID
+	c.nextID++
+	if err := writePacket(c.conn, id, typ, body); err != nil {
+		return 0, "", err
+	}
+	for {
+		respID, respType, respBody, err := readPacket(c.rd)
+		if err != nil {
+			return 0, "", err
+		}
+		if typ == typeAuth && respType == typeResponse {
+			continue
+		}
+		if respID != id && respID != -1 {
+			return 0, "", fmt.Errorf("unexpected response id %d, expected %d", respID, id)
+		}
+		return respID, respBody, nil
+	}
+}
+
+func (c *Client) deadline() error {
+	if c.timeout > 0 {
+		return c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	return nil
+}
+
+func writePacket(w io.Writer, id, typ int32, body string) error {
+	buf := make([]byte, 12, 14+len(body))
+	binary.LittleEndian.PutUint32(buf[0:], uint32(10+len(body)))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(id))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(typ))
+	buf = append(buf, body...)
+	buf = append(buf, 0, 0)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readPacket(r io.Reader) (id, typ int32, body string, err error) {
+	var header [12]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, "", err
+	}
+	length := int32(binary.LittleEndian.Uint32(header[0:]))
+	if length < 10 || length > maxPacketSize {
+		return 0, 0, "", fmt.Errorf("invalid packet length %d", length)
+	}
+	id = int32(binary.LittleEndian.Uint32(header[4:]))
+	typ = int32(binary.LittleEndian.Uint32(header[8:]))
+	payload := make([]byte, length-8)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return 0, 0, "", err
+	}
+	// Strip the body's null terminator and the trailing empty string.
+	return id, typ, string(payload[:len(payload)-2]), nil
+}
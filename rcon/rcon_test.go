@@ -0,0 +1,249 @@
+package rcon
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/rules"
+)
+
+// fakeServer is an in-process RCON server recording the commands it receives.
+type fakeServer struct {
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	commands []string
+	failNext int // number of commands to run without answering before dropping the connection
+}
+
+func newFakeServer(t *testing.T, password string) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, password: password}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := false
+	for {
+		id, typ, body, err := readPacket(rd)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case typeAuth:
+			// Like Minecraft, send an empty response value before the auth response.
+			_ = writePacket(conn, id, typeResponse, "")
+			if body != s.password {
+				_ = writePacket(conn, -1, typeCommand, "")
+				return
+			}
+			authed = true
+			_ = writePacket(conn, id, typeCommand, "")
+		case typeCommand:
+			if !authed {
+				return
+			}
+			s.mu.Lock()
+			s.commands = append(s.commands, body)
+			drop := s.failNext > 0
+			if drop {
+				s.failNext--
+			}
+			s.mu.Unlock()
+			if drop {
+				return
+			}
+			res := "ran " + body
+			if body == "big" {
+				res = strings.Repeat("x", 10000)
+			}
+			// Like Minecraft, split long responses into packets of 4096 bytes.
+			for len(res) > 4096 {
+				_ = writePacket(conn, id, typeResponse, res[:4096])
+				res = res[4096:]
+			}
+			_ = writePacket(conn, id, typeResponse, res)
+		default:
+			// Minecraft answers unknown packet types.
+			_ = writePacket(conn, id, typeResponse, "Unknown request 0")
+		}
+	}
+}
+
+func (s *fakeServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func TestClient(t *testing.T) {
+	s := newFakeServer(t, "secret")
+
+	if _, err := Dial(s.ln.Addr().String(), "wrong", time.Second); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("expected ErrAuthFailed, got %v", err)
+	}
+
+	c, err := Dial(s.ln.Addr().String(), "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := c.Command("list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "ran list" {
+		t.Errorf("unexpected response %q", res)
+	}
+	if res, err = c.Command("big"); err != nil || len(res) != 10000 {
+		t.Fatalf("expected response of 10000 bytes, got %d bytes, %v", len(res), err)
+	}
+	if res, err = c.Command("list"); err != nil || res != "ran list" {
+		t.Errorf("unexpected response after long response %q, %v", res, err)
+	}
+}
+
+func TestListener(t *testing.T) {
+	flaky := newFakeServer(t, "secret")
+	flaky.failNext = 1
+	healthy := newFakeServer(t, "secret")
+
+	var mu sync.Mutex
+	var failed []string
+	l := &Listener{
+		Servers: []Server{
+			{Name: "flaky", Address: flaky.ln.Addr().String(), Password: "secret"},
+			{Name: "healthy", Address: healthy.ln.Addr().String(), Password: "secret"},
+			{Name: "wrong-password", Address: healthy.ln.Addr().String(), Password: "wrong"},
+		},
+		Commands:      []string{"give {username} diamond 1", "say thanks for voting on {service}"},
+		RetryInterval: time.Millisecond,
+		OnErr: func(s Server, _ *votifier.Vote, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, s.Name)
+		},
+	}
+
+	handled := false
+	vl := l.VoteListener(func(*votifier.Vote, votifier.Protocol) error {
+		handled = true
+		return nil
+	})
+	if err := vl(&votifier.Vote{Username: "golang", ServiceName: "site"}, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	if !handled {
+		t.Error("next listener was not called")
+	}
+	l.Wait()
+
+	// The unanswered command is not sent again, the following one is.
+	want := []string{"give golang diamond 1", "say thanks for voting on site"}
+	for _, s := range []*fakeServer{flaky, healthy} {
+		if got := s.received(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("expected commands %q, got %q", want, got)
+		}
+	}
+	sort.Strings(failed)
+	if len(failed) != 2 || failed[0] != "flaky" || failed[1] != "wrong-password" {
+		t.Errorf("expected flaky and wrong-password to fail, got %v", failed)
+	}
+
+	for _, username := range []string{"@a", "golang @a", "", "abcdefghijklmnopq"} {
+		if err := vl(&votifier.Vote{Username: username}, votifier.V2); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("%q: expected ErrInvalidUsername, got %v", username, err)
+		}
+	}
+	if got := healthy.received(); len(got) != 2 {
+		t.Errorf("expected no commands for invalid usernames, got %q", got)
+	}
+
+	// Run as a rules executor.
+	l.Servers = l.Servers[1:2]
+	err := l.Execute(&votifier.Vote{Username: "golang"}, rules.Action{Type: "command", Params: map[string]string{"command": "kill golang"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Wait()
+	if got := healthy.received(); got[len(got)-1] != "kill golang" {
+		t.Errorf("expected action command to run, got %q", got)
+	}
+	err = l.Execute(&votifier.Vote{Username: "@a"}, rules.Action{Type: "command", Params: map[string]string{"command": "kill @a"}})
+	if !errors.Is(err, ErrInvalidUsername) {
+		t.Errorf("expected ErrInvalidUsername, got %v", err)
+	}
+
+	// Votes rejected by next run no commands.
+	n := len(healthy.received())
+	rejected := l.VoteListener(func(*votifier.Vote, votifier.Protocol) error { return errors.New("rejected") })
+	if err = rejected(&votifier.Vote{Username: "golang"}, votifier.V2); err == nil {
+		t.Error("expected error of next listener")
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := healthy.received(); len(got) != n {
+		t.Errorf("expected no commands for rejected vote, got %q", got[n:])
+	}
+	if err = vl(&votifier.Vote{Username: "golang"}, votifier.V2); !errors.Is(err, ErrListenerClosed) {
+		t.Errorf("expected ErrListenerClosed, got %v", err)
+	}
+}
+
+func TestListenerCloseCancelsRetries(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	failed := make(chan error, 1)
+	l := &Listener{
+		Servers:       []Server{{Name: "down", Address: addr}},
+		Commands:      []string{"say {username}"},
+		RetryInterval: time.Hour,
+		OnErr:         func(_ Server, _ *votifier.Vote, err error) { failed <- err },
+	}
+	start := time.Now()
+	if err = l.VoteListener(nil)(&votifier.Vote{Username: "golang"}, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected Close to cancel the retry, took %s", d)
+	}
+	select {
+	case <-failed:
+	default:
+		t.Error("expected the failed server to be reported")
+	}
+}
@@ -0,0 +1,159 @@
+package rules
+
+import (
+	"strconv"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/internal/placeholder"
+	"go.minekube.com/votifier/leaderboard"
+	"go.minekube.com/votifier/streak"
+)
+
+// Stats provides the player statistics rules can depend on.
+type Stats interface {
+	// Streak returns the player's current streak on a service.
+	Streak(username, service string) int
+	// Count returns the player's votes in the period containing at.
+	Count(username string, period leaderboard.Period, at time.Time) int
+}
+
+// TrackerStats provides Stats from a streak tracker and a leaderboard.
+// Both must have recorded the vote before it is evaluated.
+type TrackerStats struct {
+	Tracker *streak.Tracker
+	Board   *leaderboard.Board
+}
+
+// Streak implements Stats.
+func (s *TrackerStats) Streak(username, service string) int {
+	return s.Tracker.Stats(username, service).Streak
+}
+
+// Count implements Stats.
+func (s *TrackerStats) Count(username string, period leaderboard.Period, at time.Time) int {
+	return s.Board.Count(username, period, at)
+}
+
+// Executor runs the actions produced by rules.
+type Executor interface {
+	Execute(vote *votifier.Vote, action Action) error
+}
+
+// ExecutorFunc is a function that implements Executor.
+type ExecutorFunc func(vote *votifier.Vote, action Action) error
+
+// Execute implements Executor.
+func (f ExecutorFunc) Execute(vote *votifier.Vote, action Action) error {
+	return f(vote, action)
+}
+
+// Engine evaluates a rule set on votes and executes the resulting actions.
+type Engine struct {
+	Rules    *RuleSet
+	Stats    Stats                                               // Required if rules have streak or count conditions.
+	Executor Executor                                            // Required to use Listener.
+	OnErr    func(vote *votifier.Vote, action Action, err error) // Optional, called for failed actions.
+}
+
+// Evaluate returns the actions of all rules matching the vote in rule order,
+// with placeholders in their parameters expanded.
+func (e *Engine) Evaluate(vote *votifier.Vote) []Action {
+	at := vote.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	at = at.In(e.Rules.location)
+
+	var (
+		actions []Action
+		extra   map[string]string
+	)
+	for i := range e.Rules.Rules {
+		r := &e.Rules.Rules[i]
+		if !e.matches(&r.When, vote, at) {
+			continue
+		}
+		if extra == nil {
+			extra = e.placeholders(vote, at)
+		}
+		for _, a := range r.Actions {
+			params := make(map[string]string, len(a.Params))
+			for k, v := range a.Params {
+				params[k] = placeholder.Expand(v, vote, extra)
+			}
+			actions = append(actions, Action{Type: a.Type, Params: params, Rule: r.Name})
+		}
+		if r.Stop {
+			break
+		}
+	}
+	return actions
+}
+
+func (e *Engine) placeholders(vote *votifier.Vote, at time.Time) map[string]string {
+	if e.Stats == nil {
+		return nil
+	}
+	return map[string]string{
+		"streak": strconv.Itoa(e.Stats.Streak(vote.Username, vote.ServiceName)),
+		"votes":  strconv.Itoa(e.Stats.Count(vote.Username, leaderboard.AllTime, at)),
+	}
+}
+
+func (e *Engine) matches(c *Conditions, vote *votifier.Vote, at time.Time) bool {
+	if len(c.Services) != 0 && !contains(c.Services, vote.ServiceName) {
+		return false
+	}
+	if c.weekdays != nil && !c.weekdays[at.Weekday()] {
+		return false
+	}
+	if c.Hours != nil && !c.Hours.matches(at.Hour()) {
+		return false
+	}
+	if c.From != nil && at.Before(*c.From) {
+		return false
+	}
+	if c.Until != nil && !at.Before(*c.Until) {
+		return false
+	}
+	if c.Streak != nil && (e.Stats == nil || !c.Streak.matches(e.Stats.Streak(vote.Username, vote.ServiceName))) {
+		return false
+	}
+	if c.Count != nil && (e.Stats == nil || !c.Count.matches(e.Stats.Count(vote.Username, c.period, at))) {
+		return false
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Listener returns a listener passing the vote on to next, if not nil, and
+// executing the actions of matching rules once next succeeded. Failed
+// actions are reported to OnErr and do not fail the vote.
+//
+// The streak tracker and leaderboard record a vote after their next
+// listener succeeded, so they must come after the engine for its rules to
+// see the vote, e.g. engine.Listener(tracker.Listener(board.Listener(nil))).
+func (e *Engine) Listener(next votifier.VoteListener) votifier.VoteListener {
+	return func(vote *votifier.Vote, protocol votifier.Protocol) error {
+		if next != nil {
+			if err := next(vote, protocol); err != nil {
+				return err
+			}
+		}
+		for _, a := range e.Evaluate(vote) {
+			if err := e.Executor.Execute(vote, a); err != nil && e.OnErr != nil {
+				e.OnErr(vote, a, err)
+			}
+		}
+		return nil
+	}
+}
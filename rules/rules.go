@@ -0,0 +1,203 @@
+// Package rules evaluates declarative reward rules on accepted votes.
+//
+// Rules are loaded from YAML or JSON:
+//
+//	timezone: Europe/Berlin
+//	rules:
+//	  - name: reward
+//	    actions:
+//	      - type: command
+//	        params: {command: "give {username} diamond 1"}
+//	  - name: every 10th vote
+//	    when:
+//	      count: {period: allTime, every: 10}
+//	    actions:
+//	      - type: command
+//	        params: {command: "give {username} emerald 5"}
+//	  - name: weekend bonus
+//	    when:
+//	      weekdays: [saturday, sunday]
+//	    actions:
+//	      - type: command
+//	        params: {command: "give {username} diamond 1"}
+//
+// Every rule whose conditions match produces its actions, which are passed
+// to an Executor. Action parameters may contain the placeholders {username},
+// {service}, {address}, {timestamp}, {streak} (on the vote's service) and
+// {votes} (all-time).
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.minekube.com/votifier/internal/decode"
+	"go.minekube.com/votifier/leaderboard"
+)
+
+// RuleSet is a list of rules and the time zone their time conditions are evaluated in.
+type RuleSet struct {
+	Timezone string `json:"timezone"` // IANA time zone name, defaults to UTC.
+	Rules    []Rule `json:"rules"`
+
+	location *time.Location
+}
+
+// Rule produces actions when all of its conditions match a vote.
+type Rule struct {
+	Name    string     `json:"name"`
+	When    Conditions `json:"when"`
+	Actions []Action   `json:"actions"`
+	Stop    bool       `json:"stop"` // Skip the remaining rules if this rule matched.
+}
+
+// Conditions of a rule. Unset conditions always match.
+type Conditions struct {
+	Services []string        `json:"services"` // The vote's service is one of these.
+	Streak   *Threshold      `json:"streak"`   // The player's streak on the vote's service.
+	Count    *CountCondition `json:"count"`    // The player's votes in a period.
+	Weekdays []string        `json:"weekdays"` // The vote was cast on one of these days, e.g. "saturday".
+	Hours    *HourRange      `json:"hours"`    // The vote was cast within these hours of the day.
+	From     *time.Time      `json:"from"`     // The vote was cast at or after this time.
+	Until    *time.Time      `json:"until"`    // The vote was cast before this time.
+
+	weekdays map[time.Weekday]bool
+	period   leaderboard.Period
+}
+
+// Threshold matches a number. Unset (zero) fields always match.
+type Threshold struct {
+	Min    int `json:"min"`
+	Max    int `json:"max"`
+	Equals int `json:"equals"`
+	Every  int `json:"every"` // The number is a multiple of Every, e.g. every 10th vote.
+}
+
+// CountCondition matches the number of votes of a player in a period,
+// including the vote being evaluated.
+type CountCondition struct {
+	Period string `json:"period"` // daily, weekly, monthly or allTime.
+	Threshold
+}
+
+// HourRange matches hours of the day from From (inclusive) to To (exclusive).
+// If From is after To, the range wraps around midnight.
+type HourRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Action is produced by a matching rule and run by an Executor.
+type Action struct {
+	Type   string            `json:"type"`   // Tells the executor what to do, e.g. "command".
+	Params map[string]string `json:"params"` // Parameters with expanded placeholders.
+	Rule   string            `json:"-"`      // Name of the rule that produced the action.
+}
+
+// Load reads a rule set from a YAML or JSON file, by its extension.
+func Load(path string) (*RuleSet, error) {
+	var rs RuleSet
+	if err := decode.File(path, &rs); err != nil {
+		return nil, err
+	}
+	if err := rs.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &rs, nil
+}
+
+// Parse parses and validates a rule set in the given format ("yaml" or "json").
+func Parse(data []byte, format string) (*RuleSet, error) {
+	var rs RuleSet
+	if err := decode.Unmarshal(data, format, &rs); err != nil {
+		return nil, err
+	}
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+var periods = map[string]leaderboard.Period{
+	"daily":   leaderboard.Daily,
+	"weekly":  leaderboard.Weekly,
+	"monthly": leaderboard.Monthly,
+	"allTime": leaderboard.AllTime,
+}
+
+// Validate checks the rule set and prepares it for evaluation.
+// It must be called before using a rule set that was not loaded with Load or Parse.
+func (rs *RuleSet) Validate() error {
+	loc, err := time.LoadLocation(rs.Timezone)
+	if err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	rs.location = loc
+
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if err = r.validate(); err != nil {
+			return fmt.Errorf("rules[%d] (%s): %w", i, r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) validate() error {
+	if len(r.Actions) == 0 {
+		return errors.New("actions: at least one action is required")
+	}
+	for i, a := range r.Actions {
+		if a.Type == "" {
+			return fmt.Errorf("actions[%d].type: required", i)
+		}
+	}
+
+	c := &r.When
+	c.weekdays = nil
+	for i, name := range c.Weekdays {
+		day, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("when.weekdays[%d]: unknown weekday %q", i, name)
+		}
+		if c.weekdays == nil {
+			c.weekdays = map[time.Weekday]bool{}
+		}
+		c.weekdays[day] = true
+	}
+	if c.Count != nil {
+		p, ok := periods[c.Count.Period]
+		if !ok {
+			return fmt.Errorf("when.count.period: unknown period %q", c.Count.Period)
+		}
+		c.period = p
+	}
+	if h := c.Hours; h != nil && (h.From < 0 || h.From > 24 || h.To < 0 || h.To > 24) {
+		return errors.New("when.hours: hours must be between 0 and 24")
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		weekdays[strings.ToLower(d.String())] = d
+	}
+}
+
+func (t *Threshold) matches(n int) bool {
+	return (t.Min == 0 || n >= t.Min) &&
+		(t.Max == 0 || n <= t.Max) &&
+		(t.Equals == 0 || n == t.Equals) &&
+		(t.Every == 0 || (n > 0 && n%t.Every == 0))
+}
+
+func (h *HourRange) matches(hour int) bool {
+	if h.From <= h.To {
+		return hour >= h.From && hour < h.To
+	}
+	return hour >= h.From || hour < h.To
+}
@@ -0,0 +1,171 @@
+package rules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/leaderboard"
+	"go.minekube.com/votifier/streak"
+)
+
+const testRules = `
+timezone: Europe/Berlin
+rules:
+  - name: reward
+    actions:
+      - type: command
+        params: {command: "give {username} diamond 1"}
+  - name: tenth vote
+    when:
+      count: {period: allTime, every: 10}
+    actions:
+      - type: command
+        params: {command: "say {username} voted {votes} times"}
+  - name: streak on site-a
+    when:
+      services: [site-a]
+      streak: {min: 7}
+    actions:
+      - type: broadcast
+        params: {message: "{username} has a {streak} day streak"}
+  - name: weekend
+    when:
+      weekdays: [Saturday, sunday]
+      hours: {from: 18, to: 2}
+    actions:
+      - type: command
+        params: {command: "give {username} diamond 1"}
+    stop: true
+  - name: never reached on weekend evenings
+    actions:
+      - type: noop
+`
+
+type fakeStats struct{ streak, count int }
+
+func (s fakeStats) Streak(string, string) int { return s.streak }
+func (s fakeStats) Count(_ string, p leaderboard.Period, _ time.Time) int {
+	if p != leaderboard.AllTime {
+		return 0
+	}
+	return s.count
+}
+
+func TestEvaluate(t *testing.T) {
+	rs, err := Parse([]byte(testRules), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Friday 2024-03-01 12:00 and Saturday 2024-03-02 23:30 in Berlin.
+	friday := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)
+	saturdayNight := time.Date(2024, 3, 2, 22, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		vote  votifier.Vote
+		stats fakeStats
+		want  []string
+	}{
+		{"base", votifier.Vote{ServiceName: "site-a", Timestamp: friday}, fakeStats{1, 1}, []string{"reward", "never reached on weekend evenings"}},
+		{"tenth vote", votifier.Vote{ServiceName: "site-b", Timestamp: friday}, fakeStats{1, 20}, []string{"reward", "tenth vote", "never reached on weekend evenings"}},
+		{"streak", votifier.Vote{ServiceName: "site-a", Timestamp: friday}, fakeStats{7, 3}, []string{"reward", "streak on site-a", "never reached on weekend evenings"}},
+		{"streak other site", votifier.Vote{ServiceName: "site-b", Timestamp: friday}, fakeStats{7, 3}, []string{"reward", "never reached on weekend evenings"}},
+		{"weekend", votifier.Vote{ServiceName: "site-b", Timestamp: saturdayNight}, fakeStats{1, 3}, []string{"reward", "weekend"}},
+	}
+	for _, tt := range tests {
+		e := &Engine{Rules: rs, Stats: tt.stats}
+		tt.vote.Username = "golang"
+		var got []string
+		for _, a := range e.Evaluate(&tt.vote) {
+			got = append(got, a.Rule)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: expected rules %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	e := &Engine{Rules: rs, Stats: fakeStats{7, 10}}
+	actions := e.Evaluate(&votifier.Vote{Username: "golang", ServiceName: "site-a", Timestamp: friday})
+	if got := actions[1].Params["command"]; got != "say golang voted 10 times" {
+		t.Errorf("unexpected expanded command %q", got)
+	}
+	if got := actions[2].Params["message"]; got != "golang has a 7 day streak" {
+		t.Errorf("unexpected expanded message %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		rules string
+		want  string
+	}{
+		{`rules: [{name: a}]`, "rules[0] (a): actions: at least one action is required"},
+		{`rules: [{name: a, actions: [{params: {}}]}]`, "rules[0] (a): actions[0].type: required"},
+		{`rules: [{name: a, when: {weekdays: [funday]}, actions: [{type: x}]}]`, `rules[0] (a): when.weekdays[0]: unknown weekday "funday"`},
+		{`rules: [{name: a, when: {count: {period: yearly}}, actions: [{type: x}]}]`, `rules[0] (a): when.count.period: unknown period "yearly"`},
+		{`rules: [{name: a, when: {colour: red}, actions: [{type: x}]}]`, `unknown field "colour"`},
+		{`timezone: Mars/Olympus`, "timezone:"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.rules), "yaml")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q): expected error containing %q, got %v", tt.rules, tt.want, err)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	rs, err := Parse([]byte(`{"rules": [
+		{"name": "a", "actions": [{"type": "ok"}, {"type": "fail"}]}
+	]}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var executed, failed []string
+	e := &Engine{
+		Rules: rs,
+		Executor: ExecutorFunc(func(_ *votifier.Vote, a Action) error {
+			executed = append(executed, a.Type)
+			if a.Type == "fail" {
+				return errors.New("failed")
+			}
+			return nil
+		}),
+		OnErr: func(_ *votifier.Vote, a Action, _ error) { failed = append(failed, a.Type) },
+	}
+	if err = e.Listener(nil)(&votifier.Vote{Username: "golang"}, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	if len(executed) != 2 || len(failed) != 1 || failed[0] != "fail" {
+		t.Errorf("unexpected execution: executed %v, failed %v", executed, failed)
+	}
+}
+
+func TestListenerSeesRecordedVote(t *testing.T) {
+	rs, err := Parse([]byte(`{"rules": [
+		{"name": "first", "when": {"streak": {"min": 1}}, "actions": [{"type": "reward"}]}
+	]}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := &streak.Tracker{}
+	var executed int
+	e := &Engine{
+		Rules: rs,
+		Stats: &TrackerStats{Tracker: tracker, Board: leaderboard.New(leaderboard.Options{})},
+		Executor: ExecutorFunc(func(*votifier.Vote, Action) error {
+			executed++
+			return nil
+		}),
+	}
+	vote := &votifier.Vote{Username: "golang", ServiceName: "site", Timestamp: time.Now()}
+	if err = e.Listener(tracker.Listener(func(*votifier.Vote, votifier.Protocol) error { return nil }))(vote, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	if executed != 1 {
+		t.Errorf("expected the rule to see the recorded streak, got %d executions", executed)
+	}
+}
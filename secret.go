@@ -0,0 +1,252 @@
+package votifier
+
+import (
+	"container/list"
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSecretNotFound is returned by a SecretSource for unknown secrets.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretSource resolves secrets such as tokens and keys by name, so they
+// don't need to be stored in config files. The secrets package has
+// implementations for files, environment variables and HashiCorp Vault.
+type SecretSource interface {
+	// Secret returns the secret with the given name, or an error wrapping
+	// ErrSecretNotFound if there is none.
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// SecretSourceFunc is a function that implements SecretSource.
+type SecretSourceFunc func(ctx context.Context, name string) (string, error)
+
+// Secret implements SecretSource.
+func (f SecretSourceFunc) Secret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// LoadPrivateKeySecret loads a private key encoded like NuVotifier's
+// private.key, see EncodePrivateKey, from a secret.
+func LoadPrivateKeySecret(ctx context.Context, src SecretSource, name string) (*rsa.PrivateKey, error) {
+	encoded, err := src.Secret(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePrivateKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", name, err)
+	}
+	return key, nil
+}
+
+// SecretTokenProvider is a TokenProvider reading the tokens of services
+// from a SecretSource. Tokens are cached, so a token changed in the
+// source is used once its cached value expired.
+//
+// Only the tokens of Services and DefaultTokenService are looked up, other
+// services use the token of DefaultTokenService. Services without a secret
+// use it too; a secret with an empty value counts as missing. If the source
+// fails, the last token read is used until the source recovers. Without
+// one, votes of the service are rejected rather than verified with the
+// default token.
+//
+// Service names are chosen by the sender of a vote. AllServices looks up
+// any name, so every new name a client sends costs a request to the
+// source. Concurrent lookups of a service are then coalesced and at most
+// MaxMisses services without a secret are remembered, but prefer listing
+// the vote sites in Services.
+type SecretTokenProvider struct {
+	Source      SecretSource
+	Name        func(service string) string     // Secret name of a service's token, defaults to the service name.
+	Services    []string                        // The services whose tokens are looked up, besides DefaultTokenService.
+	AllServices bool                            // Look up the token of any service, see above.
+	TTL         time.Duration                   // How long tokens are cached, defaults to 5 minutes.
+	Timeout     time.Duration                   // Timeout of reading a secret, defaults to 5 seconds.
+	MaxMisses   int                             // Services without a secret cached for the TTL, defaults to 1000.
+	OnErr       func(service string, err error) // Optional, called if a token could not be read.
+
+	mu       sync.Mutex
+	cache    map[string]cachedToken   // services with a token
+	misses   map[string]*list.Element // services without a token, values are missedToken
+	lru      list.List                // of misses, most recently missed first
+	inflight map[string]*tokenLookup
+}
+
+type cachedToken struct {
+	token   string
+	fetched time.Time
+}
+
+type missedToken struct {
+	service string
+	fetched time.Time
+}
+
+// tokenLookup is a lookup of a service's token in the source that
+// concurrent lookups of the same service wait for.
+type tokenLookup struct {
+	done  chan struct{}
+	token string
+	found bool
+	err   error // set if the source failed and there is no cached token
+}
+
+var _ MultiTokenProvider = (*SecretTokenProvider)(nil)
+
+// Token implements TokenProvider. It returns an empty string if neither
+// the service nor DefaultTokenService has a token.
+func (p *SecretTokenProvider) Token(service string) string {
+	if tokens := p.Tokens(service); len(tokens) != 0 {
+		return tokens[0]
+	}
+	return ""
+}
+
+// Tokens implements MultiTokenProvider. It returns nil if neither the
+// service nor DefaultTokenService has a token, or if the service's token
+// could not be read.
+func (p *SecretTokenProvider) Tokens(service string) []string {
+	token, found, err := p.lookup(service)
+	if found {
+		return []string{token}
+	}
+	if err != nil || service == DefaultTokenService {
+		// The service may have a token of its own, don't fall back.
+		return nil
+	}
+	if token, found, _ = p.lookup(DefaultTokenService); found {
+		return []string{token}
+	}
+	return nil
+}
+
+// lookup returns the token of the service and whether it has one. It
+// fails if the source failed and no token of the service is cached.
+func (p *SecretTokenProvider) lookup(service string) (string, bool, error) {
+	if !p.known(service) {
+		return "", false, nil
+	}
+	ttl := p.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	now := timeNow()
+	p.mu.Lock()
+	if cached, ok := p.cache[service]; ok && now.Sub(cached.fetched) < ttl {
+		p.mu.Unlock()
+		return cached.token, true, nil
+	}
+	if e, ok := p.misses[service]; ok && now.Sub(e.Value.(missedToken).fetched) < ttl {
+		p.mu.Unlock()
+		return "", false, nil
+	}
+	if l, ok := p.inflight[service]; ok {
+		p.mu.Unlock()
+		<-l.done
+		return l.token, l.found, l.err
+	}
+	l := &tokenLookup{done: make(chan struct{})}
+	if p.inflight == nil {
+		p.inflight = map[string]*tokenLookup{}
+	}
+	p.inflight[service] = l
+	p.mu.Unlock()
+
+	l.token, l.found, l.err = p.fetch(service, now)
+	p.mu.Lock()
+	delete(p.inflight, service)
+	p.mu.Unlock()
+	close(l.done)
+	return l.token, l.found, l.err
+}
+
+// known reports whether the token of the service is looked up in the source.
+func (p *SecretTokenProvider) known(service string) bool {
+	if p.AllServices || service == DefaultTokenService {
+		return true
+	}
+	for _, s := range p.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch reads the token of the service from the source and caches it.
+func (p *SecretTokenProvider) fetch(service string, now time.Time) (string, bool, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	name := service
+	if p.Name != nil {
+		name = p.Name(service)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	token, err := p.Source.Secret(ctx, name)
+	if err == nil && token == "" {
+		// An empty token would let anyone sign votes.
+		err = fmt.Errorf("secret %s is empty: %w", name, ErrSecretNotFound)
+	}
+
+	if err != nil && !errors.Is(err, ErrSecretNotFound) {
+		if p.OnErr != nil {
+			p.OnErr(service, err)
+		}
+		// Keep using the last token read, if any.
+		p.mu.Lock()
+		cached, ok := p.cache[service]
+		p.mu.Unlock()
+		if !ok {
+			return "", false, err
+		}
+		return cached.token, true, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		delete(p.cache, service)
+		p.addMissLocked(service, now)
+		return "", false, nil
+	}
+	if p.cache == nil {
+		p.cache = map[string]cachedToken{}
+	}
+	p.cache[service] = cachedToken{token: token, fetched: now}
+	p.forgetMissLocked(service)
+	return token, true, nil
+}
+
+func (p *SecretTokenProvider) addMissLocked(service string, now time.Time) {
+	if p.misses == nil {
+		p.misses = map[string]*list.Element{}
+	}
+	if e, ok := p.misses[service]; ok {
+		e.Value = missedToken{service: service, fetched: now}
+		p.lru.MoveToFront(e)
+		return
+	}
+	p.misses[service] = p.lru.PushFront(missedToken{service: service, fetched: now})
+	max := p.MaxMisses
+	if max <= 0 {
+		max = 1000
+	}
+	for p.lru.Len() > max {
+		p.forgetMissLocked(p.lru.Back().Value.(missedToken).service)
+	}
+}
+
+func (p *SecretTokenProvider) forgetMissLocked(service string) {
+	if e, ok := p.misses[service]; ok {
+		p.lru.Remove(e)
+		delete(p.misses, service)
+	}
+}
@@ -0,0 +1,198 @@
+package votifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSecretTokenProvider(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	stubNow(t, now)
+	secrets := map[string]string{"token-site": "v1", "token-default": "def"}
+	var fail bool
+	var reads int
+	var names []string
+	var errs []string
+	p := &SecretTokenProvider{
+		Source: SecretSourceFunc(func(_ context.Context, name string) (string, error) {
+			reads++
+			names = append(names, name)
+			if fail {
+				return "", errors.New("unavailable")
+			}
+			if s, ok := secrets[name]; ok {
+				return s, nil
+			}
+			return "", fmt.Errorf("%s: %w", name, ErrSecretNotFound)
+		}),
+		Name:     func(service string) string { return "token-" + service },
+		Services: []string{"site", "new"},
+		TTL:      time.Minute,
+		OnErr:    func(service string, err error) { errs = append(errs, service) },
+	}
+
+	if got := p.Token("site"); got != "v1" {
+		t.Errorf("expected the site token, got %q", got)
+	}
+	if got := p.Token("other"); got != "def" {
+		t.Errorf("expected the default token, got %q", got)
+	}
+	secrets["token-site"] = "v2"
+	reads = 0
+	if got := p.Token("site"); got != "v1" || reads != 0 {
+		t.Errorf("expected the cached token, got %q after %d reads", got, reads)
+	}
+
+	stubNow(t, now.Add(time.Minute))
+	if got := p.Token("site"); got != "v2" {
+		t.Errorf("expected the changed token after the TTL, got %q", got)
+	}
+
+	stubNow(t, now.Add(2*time.Minute))
+	fail = true
+	if got := p.Token("site"); got != "v2" {
+		t.Errorf("expected the last token while the source fails, got %q", got)
+	}
+	if len(errs) != 1 || errs[0] != "site" {
+		t.Errorf("expected the error to be reported, got %v", errs)
+	}
+
+	// Without a token read before, the default token must not be used,
+	// the service may have a token of its own.
+	if tokens := p.Tokens("new"); tokens != nil {
+		t.Errorf("expected no tokens while the source fails, got %q", tokens)
+	}
+
+	// Services not listed are not looked up and use the default token.
+	fail = false
+	names = nil
+	if got := p.Token("unlisted"); got != "def" || len(names) != 1 || names[0] != "token-default" {
+		t.Errorf("expected the default token, got %q after reading %q", got, names)
+	}
+}
+
+func TestSecretTokenProviderRejectsForgedVotes(t *testing.T) {
+	for name, secrets := range map[string]map[string]string{
+		"missing": {},
+		"empty":   {"attacker": "", DefaultTokenService: ""},
+	} {
+		p := &SecretTokenProvider{Source: SecretSourceFunc(func(_ context.Context, name string) (string, error) {
+			if s, ok := secrets[name]; ok {
+				return s, nil
+			}
+			return "", ErrSecretNotFound
+		})}
+		if tokens := p.Tokens("attacker"); tokens != nil {
+			t.Errorf("%s: expected no tokens, got %q", name, tokens)
+		}
+
+		// A vote signed with an empty key must not verify.
+		v := Vote{ServiceName: "attacker", Username: "golang"}
+		data, err := v.EncodeV2("", "xyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d Vote
+		if _, err = d.DecodeV2TokenID(data, p, "xyz"); err == nil {
+			t.Errorf("%s: expected forged vote to be rejected", name)
+		}
+	}
+}
+
+func TestSecretTokenProviderBoundsMisses(t *testing.T) {
+	var mu sync.Mutex
+	reads := map[string]int{}
+	release := make(chan struct{})
+	p := &SecretTokenProvider{
+		Source: SecretSourceFunc(func(_ context.Context, name string) (string, error) {
+			mu.Lock()
+			reads[name]++
+			mu.Unlock()
+			if name == "slow" {
+				<-release
+			}
+			if name == DefaultTokenService {
+				return "def", nil
+			}
+			return "", ErrSecretNotFound
+		}),
+		AllServices: true,
+		MaxMisses:   2,
+	}
+
+	// Concurrent lookups of a service are coalesced.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := p.Token("slow"); got != "def" {
+				t.Errorf("expected the default token, got %q", got)
+			}
+		}()
+	}
+	for {
+		mu.Lock()
+		n := reads["slow"]
+		mu.Unlock()
+		if n != 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if reads["slow"] != 1 {
+		t.Errorf("expected one read of coalesced lookups, got %d", reads["slow"])
+	}
+
+	for i := 0; i < 100; i++ {
+		p.Token(fmt.Sprintf("random-%d", i))
+	}
+	p.mu.Lock()
+	misses := len(p.misses)
+	p.mu.Unlock()
+	if misses != 2 || p.lru.Len() != 2 {
+		t.Errorf("expected 2 remembered misses, got %d", misses)
+	}
+
+	// Without AllServices, other names than Services are not looked up.
+	p.AllServices = false
+	p.Services = []string{"site"}
+	before := len(reads)
+	if got := p.Token("unknown-site"); got != "def" {
+		t.Errorf("expected the default token, got %q", got)
+	}
+	if len(reads) != before {
+		t.Error("expected an unknown service not to be looked up")
+	}
+}
+
+func TestLoadPrivateKeySecret(t *testing.T) {
+	key := generateKeys(t, 1)[0]
+	encoded, err := EncodePrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := SecretSourceFunc(func(_ context.Context, name string) (string, error) {
+		if name == "rsa" {
+			return encoded, nil
+		}
+		return "invalid", nil
+	})
+	loaded, err := LoadPrivateKeySecret(context.Background(), src, "rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(key) {
+		t.Error("loaded key does not match")
+	}
+	if _, err = LoadPrivateKeySecret(context.Background(), src, "other"); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+}
@@ -0,0 +1,69 @@
+// Package secrets implements votifier.SecretSource for files, environment
+// variables and the HashiCorp Vault KV secrets engine.
+//
+// A server can read its tokens from Vault instead of a config file:
+//
+//	src := &secrets.Vault{
+//		Address: "https://vault.example.org:8200",
+//		Token:   os.Getenv("VAULT_TOKEN"),
+//	}
+//	record := votifier.ReceiverRecord{
+//		TokenProvider: &votifier.SecretTokenProvider{
+//			Source:   src,
+//			Name:     func(service string) string { return "votifier/tokens#" + service },
+//			Services: []string{"example.org"},
+//		},
+//	}
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.minekube.com/votifier"
+)
+
+// File reads secrets from files in a directory, such as Docker or
+// Kubernetes secrets mounted at /run/secrets. The name of a secret is its
+// file name relative to Dir; a trailing newline is removed.
+type File struct {
+	Dir string
+}
+
+var _ votifier.SecretSource = (*File)(nil)
+
+// Secret implements votifier.SecretSource.
+func (f *File) Secret(_ context.Context, name string) (string, error) {
+	clean := filepath.Clean(name)
+	if name == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid secret file name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(f.Dir, clean))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("secret file %s: %w", name, votifier.ErrSecretNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Env reads secrets from environment variables named Prefix followed by
+// the name of the secret.
+type Env struct {
+	Prefix string
+}
+
+var _ votifier.SecretSource = (*Env)(nil)
+
+// Secret implements votifier.SecretSource.
+func (e *Env) Secret(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(e.Prefix + name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s%s: %w", e.Prefix, name, votifier.ErrSecretNotFound)
+	}
+	return value, nil
+}
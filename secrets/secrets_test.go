@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.minekube.com/votifier"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "site-token"), []byte("abc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src := &File{Dir: dir}
+	ctx := context.Background()
+	if got, err := src.Secret(ctx, "site-token"); err != nil || got != "abc" {
+		t.Errorf("expected secret without newline, got %q, %v", got, err)
+	}
+	if _, err := src.Secret(ctx, "missing"); !errors.Is(err, votifier.ErrSecretNotFound) {
+		t.Errorf("expected ErrSecretNotFound, got %v", err)
+	}
+	for _, name := range []string{"", "../secret", "/etc/passwd", "a/../../b"} {
+		if _, err := src.Secret(ctx, name); err == nil || errors.Is(err, votifier.ErrSecretNotFound) {
+			t.Errorf("%q: expected an invalid name error, got %v", name, err)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("VOTIFIER_SITE", "abc")
+	src := &Env{Prefix: "VOTIFIER_"}
+	if got, err := src.Secret(context.Background(), "SITE"); err != nil || got != "abc" {
+		t.Errorf("unexpected secret %q, %v", got, err)
+	}
+	if _, err := src.Secret(context.Background(), "OTHER"); !errors.Is(err, votifier.ErrSecretNotFound) {
+		t.Errorf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+// fakeVault serves KV v1 and v2 secrets under the "secret" mount.
+func fakeVault(secrets map[string]map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/v1/secret/")
+		v2 := strings.HasPrefix(path, "data/")
+		data, ok := secrets[strings.TrimPrefix(path, "data/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		var body any = map[string]any{"data": data}
+		if v2 {
+			body = map[string]any{"data": map[string]any{"data": data, "metadata": map[string]any{"version": 3}}}
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func TestVault(t *testing.T) {
+	vault := fakeVault(map[string]map[string]any{
+		"votifier/tokens": {"example.org": "abc", "value": "default", "port": 8192},
+		"votifier/a b":    {"value": "escaped"},
+	})
+	defer vault.Close()
+	ctx := context.Background()
+
+	for _, version := range []int{1, 2} {
+		src := &Vault{Address: vault.URL, Token: "vault-token", KVVersion: version}
+		tests := []struct {
+			name, want string
+		}{
+			{"votifier/tokens#example.org", "abc"},
+			{"votifier/tokens", "default"},
+			{"/votifier/a b", "escaped"},
+		}
+		for _, tt := range tests {
+			if got, err := src.Secret(ctx, tt.name); err != nil || got != tt.want {
+				t.Errorf("v%d %q: expected %q, got %q, %v", version, tt.name, tt.want, got, err)
+			}
+		}
+		for _, name := range []string{"votifier/missing", "votifier/tokens#missing"} {
+			if _, err := src.Secret(ctx, name); !errors.Is(err, votifier.ErrSecretNotFound) {
+				t.Errorf("v%d %q: expected ErrSecretNotFound, got %v", version, name, err)
+			}
+		}
+	}
+
+	src := &Vault{Address: vault.URL, Token: "vault-token"}
+	if _, err := src.Secret(ctx, "votifier/tokens#port"); err == nil || !strings.Contains(err.Error(), "not a string") {
+		t.Errorf("expected a not a string error, got %v", err)
+	}
+	if _, err := src.Secret(ctx, "votifier/../sys#x"); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("expected an invalid name error, got %v", err)
+	}
+	src.Token = "wrong"
+	if _, err := src.Secret(ctx, "votifier/tokens"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected the vault error, got %v", err)
+	}
+}
+
+func TestVaultTokenProvider(t *testing.T) {
+	vault := fakeVault(map[string]map[string]any{
+		"votifier/tokens": {"example.org": "abc", "default": "def"},
+	})
+	defer vault.Close()
+	p := &votifier.SecretTokenProvider{
+		Source:   &Vault{Address: vault.URL, Token: "vault-token"},
+		Name:     func(service string) string { return "votifier/tokens#" + service },
+		Services: []string{"example.org"},
+	}
+	if got := p.Token("example.org"); got != "abc" {
+		t.Errorf("expected the service token, got %q", got)
+	}
+	if got := p.Token("other"); got != "def" {
+		t.Errorf("expected the default token, got %q", got)
+	}
+}
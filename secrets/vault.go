@@ -0,0 +1,140 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+// DefaultVaultField is the field of a Vault secret used if a name has none.
+const DefaultVaultField = "value"
+
+// Vault reads secrets from a HashiCorp Vault KV secrets engine, or a
+// compatible HTTP API.
+//
+// The name of a secret is its path in the engine, optionally followed by
+// "#" and a field of the secret, e.g. "votifier/tokens#example.org".
+type Vault struct {
+	Address   string       // Required, e.g. "https://vault.example.org:8200".
+	Token     string       // Required, sent in the X-Vault-Token header.
+	Mount     string       // Mount path of the engine, defaults to "secret".
+	KVVersion int          // Version of the KV engine, 1 or 2, defaults to 2.
+	Namespace string       // Optional Vault Enterprise namespace.
+	Client    *http.Client // Defaults to a client with a 10 second timeout.
+}
+
+var _ votifier.SecretSource = (*Vault)(nil)
+
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// Secret implements votifier.SecretSource.
+func (v *Vault) Secret(ctx context.Context, name string) (string, error) {
+	path, field := name, DefaultVaultField
+	if i := strings.LastIndexByte(name, '#'); i >= 0 {
+		path, field = name[:i], name[i+1:]
+	}
+	path = strings.Trim(path, "/")
+	if path == "" || field == "" || hasDotSegment(path) {
+		return "", fmt.Errorf("invalid vault secret name %q", name)
+	}
+
+	mount := strings.Trim(v.Mount, "/")
+	if mount == "" {
+		mount = "secret"
+	}
+	endpoint := strings.TrimRight(v.Address, "/") + "/v1/" + mount + "/"
+	if v.KVVersion != 1 {
+		endpoint += "data/"
+	}
+	endpoint += escapePath(path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	client := v.Client
+	if client == nil {
+		client = defaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("vault secret %s: %w", path, votifier.ErrSecretNotFound)
+	case res.StatusCode != http.StatusOK:
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(body, &e) == nil && len(e.Errors) != 0 {
+			return "", fmt.Errorf("vault secret %s: %s: %s", path, res.Status, strings.Join(e.Errors, "; "))
+		}
+		return "", fmt.Errorf("vault secret %s: %s", path, res.Status)
+	}
+
+	var data map[string]any
+	if v.KVVersion == 1 {
+		var r struct {
+			Data map[string]any `json:"data"`
+		}
+		err = json.Unmarshal(body, &r)
+		data = r.Data
+	} else {
+		var r struct {
+			Data struct {
+				Data map[string]any `json:"data"`
+			} `json:"data"`
+		}
+		err = json.Unmarshal(body, &r)
+		data = r.Data.Data
+	}
+	if err != nil {
+		return "", fmt.Errorf("vault secret %s: invalid response: %w", path, err)
+	}
+	value, ok := data[field]
+	if !ok || value == nil {
+		// KV v2 returns no data for deleted secrets.
+		return "", fmt.Errorf("vault secret %s field %s: %w", path, field, votifier.ErrSecretNotFound)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s field %s is not a string", path, field)
+	}
+	return s, nil
+}
+
+func hasDotSegment(path string) bool {
+	for _, s := range strings.Split(path, "/") {
+		if s == "." || s == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// escapePath escapes the segments of a secret path.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
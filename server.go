@@ -2,6 +2,7 @@ package votifier
 
 import (
 	"bytes"
+	"context"
 	"crypto/rsa"
 	"encoding/binary"
 	"encoding/json"
@@ -9,6 +10,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,23 +20,94 @@ type Protocol int
 
 // Protocols versions supported.
 const (
-	V1 Protocol = iota + 1 // Uses base64 encoded RSA public key as token for vote verification.
-	V2                     // Uses any token string for vote verification.
+	V1   Protocol = iota + 1 // Uses base64 encoded RSA public key as token for vote verification.
+	V2                       // Uses any token string for vote verification.
+	HTTP                     // Votes signed with v2 tokens submitted over HTTP, see the httpbridge package.
 )
 
-// VoteListener takes a vote and an int describing the protocol version (1 or 2).
+// VoteListener takes a vote and the protocol it was received with: V1, V2
+// or HTTP.
 type VoteListener func(*Vote, Protocol) error
 
+// SkipCount is returned by a vote listener to accept a vote without
+// counting it in the server's Stats, e.g. for synthetic health check votes.
+// It is not treated as an error.
+var SkipCount = errors.New("skip counting vote")
+
+// VoteInfo describes how a vote was received.
+type VoteInfo struct {
+	Protocol   Protocol
+	RemoteAddr net.Addr // Address of the connection the vote arrived on.
+	Listener   string   // Name of the listener the vote arrived on, see Server.AddListener.
+	KeyID      string   // ID of the KeyRing key that decrypted a v1 vote.
+	TokenID    string   // TokenID of the token version that verified a v2 vote.
+}
+
+// VoteInfoListener is like VoteListener but also receives details about how the vote was received.
+type VoteInfoListener func(*Vote, *VoteInfo) error
+
 type ReceiverRecord struct {
 	PrivateKey    *rsa.PrivateKey // v1
+	KeyRing       *KeyRing        // v1, used instead of PrivateKey if set
 	TokenProvider TokenProvider   // v2
 }
 
+// ActiveKey returns the key v1 votes should be encrypted for, or nil if
+// the record doesn't accept v1 votes.
+func (r *ReceiverRecord) ActiveKey() *rsa.PrivateKey {
+	if r.KeyRing != nil {
+		_, key := r.KeyRing.Active()
+		return key
+	}
+	return r.PrivateKey
+}
+
+// ErrServerClosed is returned by Serve and ServeAll after Close or Shutdown.
+var ErrServerClosed = errors.New("votifier: server closed")
+
 // Server represents a Votifier server.
+//
+// A server can serve on multiple listeners, e.g. an IPv4 and an IPv6 port,
+// a Unix socket and a TLS port created with tls.NewListener, which share
+// its records, handler and stats.
 type Server struct {
-	VoteHandler VoteListener // Required vote handler
-	Records     []ReceiverRecord
-	OnErr       func(net.Conn, error) // Optional connection handler
+	VoteHandler     VoteListener     // Required vote handler, unless VoteInfoHandler is set
+	VoteInfoHandler VoteInfoListener // Optional, used instead of VoteHandler if set
+	Records         []ReceiverRecord
+	OnErr           func(net.Conn, error) // Optional connection handler
+	Timeout         time.Duration         // Deadline of a connection, defaults to 5 seconds.
+	Accept          func(net.Conn) error  // Optional, closes connections it returns an error for, e.g. to rate limit clients.
+
+	connections, votes, failures atomic.Uint64
+
+	mu        sync.Mutex
+	listeners []*namedListener
+	group     chan error // receives the first error of the last Serve call's listeners
+	conns     map[net.Conn]struct{}
+	active    sync.WaitGroup // connections being handled
+	closed    bool
+}
+
+type namedListener struct {
+	net.Listener
+	name  string
+	group chan error // of the Serve call serving the listener, nil if not served
+}
+
+// ServerStats are counters of a running server.
+type ServerStats struct {
+	Connections uint64 // Accepted connections.
+	Votes       uint64 // Votes accepted by the vote handler, except those it returned SkipCount for.
+	Errors      uint64 // Connections that failed, e.g. because a vote could not be decoded.
+}
+
+// Stats returns the counters of the server.
+func (s *Server) Stats() ServerStats {
+	return ServerStats{
+		Connections: s.connections.Load(),
+		Votes:       s.votes.Load(),
+		Errors:      s.failures.Load(),
+	}
 }
 
 // ListenAndServe binds to a specified address-port pair and starts serving Votifier requests.
@@ -46,40 +120,248 @@ func (s *Server) ListenAndServe(address string) error {
 	return s.Serve(l)
 }
 
-// Serve serves requests on the provided listener.
+// AddListener adds a listener the server accepts connections on, named
+// in the VoteInfo of votes arriving on it. If name is empty, the listener's
+// address is used. Listeners added while the server is serving are served
+// immediately, those added after Close or Shutdown are closed.
+func (s *Server) AddListener(name string, ln net.Listener) {
+	if name == "" {
+		name = ln.Addr().String()
+	}
+	l := &namedListener{Listener: ln, name: name}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		ln.Close()
+		return
+	}
+	s.listeners = append(s.listeners, l)
+	if s.group != nil {
+		s.startLocked(s.group, l)
+	}
+}
+
+// Listeners returns the names of the server's listeners that are served or
+// waiting to be served. Listeners are removed when their Serve call returns.
+func (s *Server) Listeners() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, len(s.listeners))
+	for i, l := range s.listeners {
+		names[i] = l.name
+	}
+	return names
+}
+
+// ErrAlreadyServing is returned by Serve and ServeAll if all listeners of
+// the server are already served by another call.
+var ErrAlreadyServing = errors.New("all listeners are already being served")
+
+// Serve serves requests on the provided listener and the listeners added
+// with AddListener that are not served yet, see ServeAll.
 func (s *Server) Serve(ln net.Listener) error {
+	return s.serve(ln)
+}
+
+// ServeAll serves requests on the listeners added with AddListener. It
+// returns once a listener fails, closing the others, or ErrServerClosed
+// after Close or Shutdown. Listeners already served by another call are
+// left to that call; if there are no others, it returns ErrAlreadyServing.
+func (s *Server) ServeAll() error {
+	return s.serve(nil)
+}
+
+func (s *Server) serve(ln net.Listener) error {
 	if len(s.Records) == 0 {
 		return errors.New("no records provided")
 	}
-	if s.VoteHandler == nil {
+	if s.VoteHandler == nil && s.VoteInfoHandler == nil {
 		return errors.New("no vote handler provided")
 	}
 
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		if ln != nil {
+			ln.Close()
+		}
+		return ErrServerClosed
+	}
+	if ln != nil {
+		// Not started by AddListener, it is started below.
+		s.listeners = append(s.listeners, &namedListener{Listener: ln, name: ln.Addr().String()})
+	}
+	if len(s.listeners) == 0 {
+		s.mu.Unlock()
+		return errors.New("no listeners provided")
+	}
+	g := make(chan error, 1)
+	var started int
+	for _, l := range s.listeners {
+		if l.group == nil {
+			s.startLocked(g, l)
+			started++
+		}
+	}
+	if started == 0 {
+		s.mu.Unlock()
+		return ErrAlreadyServing
+	}
+	s.group = g
+	s.mu.Unlock()
+
+	err := <-g
+	// The first listener to fail stops the others of this call. Remove
+	// them so they can neither be listed nor block later calls.
+	s.mu.Lock()
+	closed := s.closed
+	if s.group == g {
+		s.group = nil
+	}
+	kept := s.listeners[:0]
+	for _, l := range s.listeners {
+		if l.group == g {
+			l.Close()
+		} else {
+			kept = append(kept, l)
+		}
+	}
+	for i := len(kept); i < len(s.listeners); i++ {
+		s.listeners[i] = nil
+	}
+	s.listeners = kept
+	s.mu.Unlock()
+	if closed {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// startLocked starts accepting connections on l as part of the Serve call of group.
+func (s *Server) startLocked(group chan error, l *namedListener) {
+	l.group = group
+	go func() {
+		err := s.acceptLoop(l)
+		select {
+		case group <- err:
+		default:
+		}
+	}()
+}
+
+func (s *Server) acceptLoop(l *namedListener) error {
 	for {
 		// Wait for a connection.
-		conn, err := ln.Accept()
+		conn, err := l.Accept()
 		if err != nil {
 			return err
 		}
-		go s.handleConn(conn)
+		if !s.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.handleConn(conn, l.name)
 	}
 }
 
-func (s *Server) handleConn(c net.Conn) {
+func (s *Server) track(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = map[net.Conn]struct{}{}
+	}
+	s.conns[c] = struct{}{}
+	s.active.Add(1)
+	return true
+}
+
+func (s *Server) untrack(c net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	s.active.Done()
+}
+
+// Close closes all listeners and connections. Serve returns ErrServerClosed.
+func (s *Server) Close() error {
+	err := s.closeListeners()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// Shutdown closes all listeners and waits for connections being handled
+// to finish, or ctx to be done. Serve returns ErrServerClosed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.closeListeners()
+	done := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) closeListeners() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	var err error
+	for _, l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil && !errors.Is(cerr, net.ErrClosed) {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *Server) handleConn(c net.Conn, listener string) {
+	defer s.untrack(c)
 	defer c.Close()
-	if err := s.HandleConn(c); err != nil && s.OnErr != nil {
-		s.OnErr(c, err)
+	s.connections.Add(1)
+	var err error
+	if s.Accept != nil {
+		err = s.Accept(c)
+	}
+	if err == nil {
+		err = s.handle(c, listener)
+	}
+	if err != nil {
+		s.failures.Add(1)
+		if s.OnErr != nil {
+			s.OnErr(c, err)
+		}
 	}
 }
 
+// HandleConn handles a single connection, e.g. one accepted by another server.
 func (s *Server) HandleConn(c net.Conn) error {
+	return s.handle(c, "")
+}
+
+func (s *Server) handle(c net.Conn, listener string) error {
 	challenge, err := randomString()
 	if err != nil {
 		// something very bad happened - only caused when /dev/urandom
 		// also returns an error, which should never happen.
 		return fmt.Errorf("error generating challenge: %v", err)
 	}
-	err = c.SetDeadline(timeNow().Add(5 * time.Second))
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	err = c.SetDeadline(timeNow().Add(timeout))
 	if err != nil {
 		return fmt.Errorf("error setting deadline: %v", err)
 	}
@@ -107,20 +389,27 @@ func (s *Server) HandleConn(c net.Conn) error {
 	isv2 := magicRead == v2Magic
 	for _, record := range s.Records {
 		v := new(Vote)
-		if !isv2 && record.PrivateKey != nil {
-			err = v.DecodeV1(data[:read], record.PrivateKey)
+		info := &VoteInfo{RemoteAddr: c.RemoteAddr(), Listener: listener}
+		if !isv2 && (record.PrivateKey != nil || record.KeyRing != nil) {
+			if record.KeyRing != nil {
+				info.KeyID, err = v.DecodeV1KeyRing(data[:read], record.KeyRing)
+			} else {
+				err = v.DecodeV1(data[:read], record.PrivateKey)
+			}
 			if err != nil {
 				continue
 			}
-			err = s.VoteHandler(v, V1)
+			info.Protocol = V1
+			err = s.handleVote(v, info)
 			continue
 		} else if record.TokenProvider != nil {
-			err = v.DecodeV2(data[:read], record.TokenProvider, challenge)
+			info.TokenID, err = v.DecodeV2TokenID(data[:read], record.TokenProvider, challenge)
 			if err != nil {
 				continue
 			}
 
-			err = s.VoteHandler(v, V2)
+			info.Protocol = V2
+			err = s.handleVote(v, info)
 			if err != nil {
 				continue
 			}
@@ -144,6 +433,22 @@ func (s *Server) HandleConn(c net.Conn) error {
 	return err
 }
 
+func (s *Server) handleVote(v *Vote, info *VoteInfo) error {
+	var err error
+	if s.VoteInfoHandler != nil {
+		err = s.VoteInfoHandler(v, info)
+	} else {
+		err = s.VoteHandler(v, info.Protocol)
+	}
+	if errors.Is(err, SkipCount) {
+		return nil
+	}
+	if err == nil {
+		s.votes.Add(1)
+	}
+	return err
+}
+
 type Result struct {
 	Status string
 }
@@ -1,12 +1,14 @@
 package votifier
 
 import (
+	"context"
 	"crypto/rsa"
 	"errors"
 	"fmt"
 	"net"
 	"reflect"
 	"testing"
+	"time"
 )
 
 var (
@@ -22,13 +24,16 @@ func TestServer(t *testing.T) {
 	}
 
 	for _, i := range Protocols {
+		i := i
 		t.Run(fmt.Sprintf("Protocol %d", i), func(t *testing.T) {
 			v := Vote{
 				ServiceName: "golang",
 				Username:    "golang",
 				Address:     "127.0.0.1",
 			}
+			received := make(chan struct{})
 			vl := func(rv *Vote, ver Protocol) error {
+				defer close(received)
 				if reflect.DeepEqual(v, *rv) {
 					t.Error("Vote received did not match original")
 				}
@@ -68,6 +73,13 @@ func TestServer(t *testing.T) {
 			err = client.SendVote(v)
 			if err != nil {
 				t.Error(err)
+				return
+			}
+
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Error("vote was not received")
 			}
 		})
 	}
@@ -111,3 +123,148 @@ func TestServerv2Panic(t *testing.T) {
 		t.Errorf("expected error %q, but got %q", expectedErr, err)
 	}
 }
+
+func TestServerVoteInfo(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	infos := make(chan *VoteInfo, 1)
+	server := Server{
+		VoteInfoHandler: func(v *Vote, info *VoteInfo) error {
+			infos <- info
+			return nil
+		},
+		Records: []ReceiverRecord{{TokenProvider: StaticTokenProvider("abcxyz")}},
+	}
+	go server.Serve(listener) //nolint:errcheck
+
+	client := NewV2Client(listener.Addr().String(), "abcxyz")
+	if err = client.SendVote(Vote{ServiceName: "golang", Username: "golang"}); err != nil {
+		t.Fatal(err)
+	}
+	info := <-infos
+	if info.Protocol != V2 {
+		t.Errorf("expected protocol v2, got %d", info.Protocol)
+	}
+	if host, _, _ := net.SplitHostPort(info.RemoteAddr.String()); host != "127.0.0.1" {
+		t.Errorf("unexpected remote address %s", info.RemoteAddr)
+	}
+	if info.TokenID != TokenID("abcxyz") {
+		t.Errorf("expected the token ID of the token, got %q", info.TokenID)
+	}
+}
+
+func TestServerMultipleListeners(t *testing.T) {
+	listen := func() net.Listener {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ln
+	}
+
+	infos := make(chan *VoteInfo, 1)
+	server := &Server{
+		VoteInfoHandler: func(v *Vote, info *VoteInfo) error {
+			infos <- info
+			return nil
+		},
+		Records: []ReceiverRecord{{TokenProvider: StaticTokenProvider("abcxyz")}},
+	}
+	first, second, late := listen(), listen(), listen()
+	server.AddListener("first", first)
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(second) }()
+
+	send := func(ln net.Listener, wantListener string) {
+		t.Helper()
+		client := NewV2Client(ln.Addr().String(), "abcxyz")
+		if err := client.SendVote(Vote{ServiceName: "golang", Username: "golang"}); err != nil {
+			t.Fatal(err)
+		}
+		if info := <-infos; info.Listener != wantListener {
+			t.Errorf("expected vote on listener %q, got %q", wantListener, info.Listener)
+		}
+	}
+	send(first, "first")
+	send(second, second.Addr().String())
+	server.AddListener("late", late)
+	send(late, "late")
+	if err := server.ServeAll(); !errors.Is(err, ErrAlreadyServing) {
+		t.Errorf("expected ErrAlreadyServing without new listeners, got %v", err)
+	}
+
+	if got := server.Listeners(); !reflect.DeepEqual(got, []string{"first", second.Addr().String(), "late"}) {
+		t.Errorf("unexpected listeners %q", got)
+	}
+	if stats := server.Stats(); stats.Votes != 3 || stats.Connections != 3 {
+		t.Errorf("expected stats to be shared by all listeners, got %+v", stats)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed, got %v", err)
+	}
+	for _, ln := range []net.Listener{first, second, late} {
+		if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+			t.Errorf("listener %s was not closed", ln.Addr())
+		}
+	}
+	if err := server.ServeAll(); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed after shutdown, got %v", err)
+	}
+}
+
+func TestServerListenerFailureStopsOthers(t *testing.T) {
+	a, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{
+		VoteHandler: func(*Vote, Protocol) error { return nil },
+		Records:     []ReceiverRecord{{TokenProvider: StaticTokenProvider("abcxyz")}},
+	}
+	served := make(chan error, 1)
+	server.AddListener("", b)
+	go func() { served <- server.Serve(a) }()
+
+	a.Close()
+	if err = <-served; err == nil || errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected the accept error, got %v", err)
+	}
+	if _, err = net.Dial("tcp", b.Addr().String()); err == nil {
+		t.Error("expected the other listener to be closed")
+	}
+
+	// The failed call's listeners are gone, new ones can be served.
+	if got := server.Listeners(); len(got) != 0 {
+		t.Errorf("expected no listeners after Serve returned, got %q", got)
+	}
+	c, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.AddListener("c", c)
+	go func() { served <- server.ServeAll() }()
+	if err = NewV2Client(c.Addr().String(), "abcxyz").SendVote(Vote{ServiceName: "golang", Username: "golang"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Listeners(); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("unexpected listeners %q", got)
+	}
+	server.Close()
+	if err = <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Log is a VoteStore backed by an append-only file of JSON records.
+// All records are indexed in memory by username and service when the log is opened.
+type Log struct {
+	mu        sync.RWMutex
+	f         *os.File
+	records   []Record
+	byUser    map[string][]int // lower-cased username -> positions in records
+	byService map[string][]int // service name -> positions in records
+	nextID    uint64
+	partial   bool // a failed write may have left an unterminated record
+}
+
+var _ VoteStore = (*Log)(nil)
+
+// Open opens or creates the log at path.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening vote log: %w", err)
+	}
+	l := &Log{
+		f:         f,
+		byUser:    map[string][]int{},
+		byService: map[string][]int{},
+		nextID:    1,
+	}
+	if err = l.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// load reads all records and truncates a partially written last record.
+// Unreadable records, for example a partial write followed by successful
+// ones, are skipped so a single broken line doesn't make the log unusable.
+func (l *Log) load() error {
+	rd := bufio.NewReader(l.f)
+	var offset int64
+	for {
+		line, err := rd.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) != 0 {
+				// Incomplete record from a crash, drop it.
+				if err = l.f.Truncate(offset); err != nil {
+					return fmt.Errorf("error truncating vote log: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading vote log: %w", err)
+		}
+		offset += int64(len(line))
+		var rec Record
+		if err = json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+		l.index(rec)
+	}
+	_, err := l.f.Seek(offset, io.SeekStart)
+	return err
+}
+
+func (l *Log) index(rec Record) {
+	pos := len(l.records)
+	l.records = append(l.records, rec)
+	user := strings.ToLower(rec.Vote.Username)
+	l.byUser[user] = append(l.byUser[user], pos)
+	l.byService[rec.Vote.ServiceName] = append(l.byService[rec.Vote.ServiceName], pos)
+	if rec.ID >= l.nextID {
+		l.nextID = rec.ID + 1
+	}
+}
+
+// Add implements VoteStore.
+func (l *Log) Add(rec Record) (Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return Record{}, os.ErrClosed
+	}
+	rec.ID = l.nextID
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return Record{}, err
+	}
+	line := append(b, '\n')
+	if l.partial {
+		// Terminate what the failed write left behind so only that record is lost.
+		line = append([]byte{'\n'}, line...)
+	}
+	if _, err = l.f.Write(line); err != nil {
+		l.partial = true
+		return Record{}, fmt.Errorf("error writing vote log: %w", err)
+	}
+	l.partial = false
+	l.index(rec)
+	return rec, nil
+}
+
+// Query implements VoteStore.
+func (l *Log) Query(q Query) ([]Record, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// Scan the smallest index that applies.
+	var candidates []int
+	all := true
+	if q.Username != "" {
+		candidates, all = l.byUser[strings.ToLower(q.Username)], false
+	}
+	if q.Service != "" {
+		if s := l.byService[q.Service]; all || len(s) < len(candidates) {
+			candidates, all = s, false
+		}
+	}
+	n := len(l.records)
+	if !all {
+		n = len(candidates)
+	}
+
+	var result []Record
+	for i := 0; i < n; i++ {
+		j := i
+		if q.Descending {
+			j = n - 1 - i
+		}
+		if !all {
+			j = candidates[j]
+		}
+		rec := l.records[j]
+		if q.matches(&rec) {
+			result = append(result, rec)
+			if q.Limit > 0 && len(result) == q.Limit {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (q *Query) matches(rec *Record) bool {
+	if q.Username != "" && !strings.EqualFold(rec.Vote.Username, q.Username) {
+		return false
+	}
+	if q.Service != "" && rec.Vote.ServiceName != q.Service {
+		return false
+	}
+	if !q.Since.IsZero() && rec.Received.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !rec.Received.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Close implements VoteStore.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "votes.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	votes := []struct{ user, service string }{
+		{"alice", "site-a"},
+		{"Bob", "site-a"},
+		{"alice", "site-b"},
+		{"ALICE", "site-a"},
+	}
+	for i, v := range votes {
+		rec, err := l.Add(Record{
+			Vote:     votifier.Vote{Username: v.user, ServiceName: v.service},
+			Protocol: votifier.V2,
+			Received: start.Add(time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.ID != uint64(i+1) {
+			t.Errorf("expected ID %d, got %d", i+1, rec.ID)
+		}
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash in the middle of writing a record.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"id":5,"vo`)
+	f.Close()
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ids := func(q Query) []uint64 {
+		recs, err := l.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []uint64
+		for _, r := range recs {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+	tests := []struct {
+		name  string
+		query Query
+		want  []uint64
+	}{
+		{"all", Query{}, []uint64{1, 2, 3, 4}},
+		{"username", Query{Username: "Alice"}, []uint64{1, 3, 4}},
+		{"service", Query{Service: "site-a"}, []uint64{1, 2, 4}},
+		{"username and service", Query{Username: "alice", Service: "site-a"}, []uint64{1, 4}},
+		{"time range", Query{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour)}, []uint64{2, 3}},
+		{"newest first", Query{Username: "alice", Descending: true, Limit: 2}, []uint64{4, 3}},
+		{"unknown", Query{Username: "carol"}, nil},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.query); len(got) != len(tt.want) || (len(got) > 0 && !equal(got, tt.want)) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// New records continue after the truncated one.
+	rec, err := l.Add(Record{Vote: votifier.Vote{Username: "carol"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID != 5 {
+		t.Errorf("expected ID 5, got %d", rec.ID)
+	}
+}
+
+func TestLogSkipsBrokenRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "votes.log")
+	// A partial write followed by successful ones.
+	data := `{"id":1,"vote":{"username":"alice"}}` + "\n" +
+		`{"id":2,"vo{"id":3,"vote":{"username":"bob"}}` + "\n" +
+		`{"id":4,"vote":{"username":"carol"}}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	recs, err := l.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].ID != 1 || recs[1].ID != 4 {
+		t.Errorf("expected records 1 and 4, got %+v", recs)
+	}
+	if rec, err := l.Add(Record{Vote: votifier.Vote{Username: "dave"}}); err != nil || rec.ID != 5 {
+		t.Errorf("expected ID 5, got %d (%v)", rec.ID, err)
+	}
+}
+
+func equal(a, b []uint64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListener(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "votes.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	called := false
+	vl := Listener(l, func(*votifier.Vote, *votifier.VoteInfo) error {
+		called = true
+		return nil
+	})
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+	if err = vl(&votifier.Vote{Username: "golang"}, &votifier.VoteInfo{Protocol: votifier.V1, RemoteAddr: addr}); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("next listener was not called")
+	}
+	recs, _ := l.Query(Query{Username: "golang"})
+	if len(recs) != 1 || recs[0].RemoteAddr != "127.0.0.1:1234" || recs[0].Protocol != votifier.V1 {
+		t.Errorf("unexpected records %+v", recs)
+	}
+
+	// Votes rejected by next are not recorded.
+	rejected := Listener(l, func(*votifier.Vote, *votifier.VoteInfo) error {
+		return errors.New("rejected")
+	})
+	if err = rejected(&votifier.Vote{Username: "rejected"}, &votifier.VoteInfo{Protocol: votifier.V2}); err == nil {
+		t.Error("expected error of next listener")
+	}
+	if recs, _ = l.Query(Query{Username: "rejected"}); len(recs) != 0 {
+		t.Errorf("expected rejected vote not to be recorded, got %+v", recs)
+	}
+}
@@ -0,0 +1,59 @@
+// Package store records accepted votes and answers queries about them.
+package store
+
+import (
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+// Record is a vote accepted by a server.
+type Record struct {
+	ID         uint64            `json:"id"` // Assigned by the store.
+	Vote       votifier.Vote     `json:"vote"`
+	Protocol   votifier.Protocol `json:"protocol"`
+	RemoteAddr string            `json:"remoteAddr,omitempty"` // Address of the vote site's connection.
+	Received   time.Time         `json:"received"`
+}
+
+// Query selects records. Zero fields match any record.
+type Query struct {
+	Username   string    // Matched case-insensitively.
+	Service    string    // Matched exactly.
+	Since      time.Time // Records received at or after this time.
+	Until      time.Time // Records received before this time.
+	Limit      int       // Maximum number of records returned, zero for no limit.
+	Descending bool      // Return the most recently added records first.
+}
+
+// VoteStore stores vote records.
+type VoteStore interface {
+	// Add stores the record and returns it with its assigned ID.
+	Add(rec Record) (Record, error)
+	// Query returns the records matching q in the order they were added.
+	Query(q Query) ([]Record, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// Listener returns a listener passing votes on to next and recording the
+// votes next accepted. If next is nil, every vote is recorded.
+func Listener(s VoteStore, next votifier.VoteInfoListener) votifier.VoteInfoListener {
+	return func(vote *votifier.Vote, info *votifier.VoteInfo) error {
+		if next != nil {
+			if err := next(vote, info); err != nil {
+				return err
+			}
+		}
+		rec := Record{
+			Vote:     *vote,
+			Protocol: info.Protocol,
+			Received: time.Now(),
+		}
+		if info.RemoteAddr != nil {
+			rec.RemoteAddr = info.RemoteAddr.String()
+		}
+		_, err := s.Add(rec)
+		return err
+	}
+}
@@ -0,0 +1,169 @@
+// Package streak tracks how consistently players vote on each vote site.
+//
+// Vote sites typically allow one vote per player every 24 hours. A Tracker
+// counts consecutive votes per player and service as a streak and detects
+// votes arriving before the cooldown elapsed, which are duplicates or come
+// from misbehaving vote sites.
+package streak
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+// ErrTooEarly is returned by the tracker's listener for votes that arrive before the cooldown elapsed.
+var ErrTooEarly = errors.New("vote arrived before cooldown elapsed")
+
+// Stats are the voting statistics of a player on a single service.
+type Stats struct {
+	Streak int       // Consecutive votes, each within the streak window of the previous one.
+	Total  int       // All counted votes.
+	Last   time.Time // Time of the last counted vote.
+}
+
+// Result is the outcome of recording a vote.
+type Result struct {
+	Stats                  // Statistics after recording the vote.
+	Early        bool      // The vote arrived before the cooldown elapsed and was not counted.
+	NextEligible time.Time // When the player can vote on the service again.
+}
+
+// Tracker computes vote streaks per player and service.
+// Usernames are matched case-insensitively.
+type Tracker struct {
+	Cooldown    time.Duration                            // Minimum time between counted votes, defaults to 24 hours.
+	Window      time.Duration                            // Maximum time between votes to keep a streak, defaults to twice the cooldown.
+	RejectEarly bool                                     // Make the listener return ErrTooEarly for early votes.
+	OnEarly     func(vote *votifier.Vote, result Result) // Optional, called for early votes.
+
+	mu      sync.Mutex
+	players map[string]map[string]*Stats // username -> service -> stats
+}
+
+func (t *Tracker) cooldown() time.Duration {
+	if t.Cooldown <= 0 {
+		return 24 * time.Hour
+	}
+	return t.Cooldown
+}
+
+func (t *Tracker) window() time.Duration {
+	if t.Window <= 0 {
+		return 2 * t.cooldown()
+	}
+	return t.Window
+}
+
+// Record counts the vote, using its timestamp as the time the vote was cast.
+func (t *Tracker) Record(vote *votifier.Vote) Result {
+	return t.record(vote, false)
+}
+
+// record computes the result of the vote and counts it unless dryRun is set.
+func (t *Tracker) record(vote *votifier.Vote, dryRun bool) Result {
+	at := vote.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := strings.ToLower(vote.Username)
+	var s Stats
+	if prev := t.players[key][vote.ServiceName]; prev != nil {
+		s = *prev
+	}
+
+	if s.Total != 0 && at.Sub(s.Last) < t.cooldown() {
+		return Result{Stats: s, Early: true, NextEligible: s.Last.Add(t.cooldown())}
+	}
+	if s.Total == 0 || at.Sub(s.Last) > t.window() {
+		s.Streak = 0
+	}
+	s.Streak++
+	s.Total++
+	s.Last = at
+	if !dryRun {
+		if t.players == nil {
+			t.players = map[string]map[string]*Stats{}
+		}
+		if t.players[key] == nil {
+			t.players[key] = map[string]*Stats{}
+		}
+		t.players[key][vote.ServiceName] = &s
+	}
+	return Result{Stats: s, NextEligible: at.Add(t.cooldown())}
+}
+
+// Stats returns the statistics of a player on a service.
+// The streak is reported as zero if it was broken by not voting within the window.
+func (t *Tracker) Stats(username, service string) Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.players[strings.ToLower(username)][service]
+	if s == nil {
+		return Stats{}
+	}
+	return t.current(*s)
+}
+
+func (t *Tracker) current(s Stats) Stats {
+	if time.Since(s.Last) > t.window() {
+		s.Streak = 0
+	}
+	return s
+}
+
+// Streak returns the current streak of a player per service.
+func (t *Tracker) Streak(username string) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	streaks := map[string]int{}
+	for service, s := range t.players[strings.ToLower(username)] {
+		streaks[service] = t.current(*s).Streak
+	}
+	return streaks
+}
+
+// NextEligible returns when a player can vote on a service again.
+// It returns the zero time if the player can vote now.
+func (t *Tracker) NextEligible(username, service string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.players[strings.ToLower(username)][service]
+	if s == nil {
+		return time.Time{}
+	}
+	if next := s.Last.Add(t.cooldown()); time.Now().Before(next) {
+		return next
+	}
+	return time.Time{}
+}
+
+// Listener returns a listener passing counted votes to next and recording
+// them once next succeeded, so a vote resent after a failure isn't early.
+// Early votes are passed on too unless RejectEarly is set.
+func (t *Tracker) Listener(next votifier.VoteListener) votifier.VoteListener {
+	return func(vote *votifier.Vote, protocol votifier.Protocol) error {
+		res := t.record(vote, true)
+		if res.Early {
+			if t.OnEarly != nil {
+				t.OnEarly(vote, res)
+			}
+			if t.RejectEarly {
+				return fmt.Errorf("%w: next vote possible at %s", ErrTooEarly, res.NextEligible.Format(time.RFC3339))
+			}
+			return next(vote, protocol)
+		}
+		if err := next(vote, protocol); err != nil {
+			return err
+		}
+		t.Record(vote)
+		return nil
+	}
+}
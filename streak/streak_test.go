@@ -0,0 +1,99 @@
+package streak
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+)
+
+func TestTracker(t *testing.T) {
+	tr := &Tracker{}
+	start := time.Now().Add(-10 * 24 * time.Hour)
+	vote := func(service string, at time.Time) Result {
+		return tr.Record(&votifier.Vote{Username: "Golang", ServiceName: service, Timestamp: at})
+	}
+
+	steps := []struct {
+		after  time.Duration
+		streak int
+		early  bool
+	}{
+		{0, 1, false},
+		{25 * time.Hour, 2, false},
+		{26 * time.Hour, 2, true}, // duplicate within cooldown
+		{49 * time.Hour, 3, false},
+		{100 * time.Hour, 1, false}, // missed more than a day, streak restarts
+		{7 * 24 * time.Hour, 1, false},
+	}
+	for i, s := range steps {
+		res := vote("site-a", start.Add(s.after))
+		if res.Streak != s.streak || res.Early != s.early {
+			t.Errorf("step %d: expected streak %d early %v, got %+v", i, s.streak, s.early, res)
+		}
+	}
+	vote("site-b", time.Now().Add(-time.Hour))
+
+	if got := tr.Stats("golang", "site-a"); got.Total != 5 {
+		t.Errorf("expected 5 counted votes, got %d", got.Total)
+	}
+	streaks := tr.Streak("GOLANG")
+	if streaks["site-a"] != 0 || streaks["site-b"] != 1 {
+		t.Errorf("unexpected streaks %v", streaks)
+	}
+	if next := tr.NextEligible("golang", "site-a"); !next.IsZero() {
+		t.Errorf("expected to be eligible on site-a, got %s", next)
+	}
+	if next := tr.NextEligible("golang", "site-b"); next.Before(time.Now().Add(22 * time.Hour)) {
+		t.Errorf("expected next eligible in 23 hours on site-b, got %s", next)
+	}
+}
+
+func TestTrackerListener(t *testing.T) {
+	var early int
+	tr := &Tracker{
+		RejectEarly: true,
+		OnEarly:     func(*votifier.Vote, Result) { early++ },
+	}
+	handled := 0
+	vl := tr.Listener(func(*votifier.Vote, votifier.Protocol) error {
+		handled++
+		return nil
+	})
+
+	v := &votifier.Vote{Username: "golang", ServiceName: "site", Timestamp: time.Now()}
+	if err := vl(v, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	if err := vl(v, votifier.V2); !errors.Is(err, ErrTooEarly) {
+		t.Errorf("expected ErrTooEarly, got %v", err)
+	}
+	if handled != 1 || early != 1 {
+		t.Errorf("expected 1 handled and 1 early vote, got %d and %d", handled, early)
+	}
+}
+
+func TestTrackerListenerFailed(t *testing.T) {
+	tr := &Tracker{RejectEarly: true}
+	fail := true
+	vl := tr.Listener(func(*votifier.Vote, votifier.Protocol) error {
+		if fail {
+			return errors.New("handler failed")
+		}
+		return nil
+	})
+
+	// The vote site resends a vote that failed, it must not be early.
+	v := &votifier.Vote{Username: "golang", ServiceName: "site", Timestamp: time.Now()}
+	if err := vl(v, votifier.V2); err == nil {
+		t.Fatal("expected error")
+	}
+	fail = false
+	if err := vl(v, votifier.V2); err != nil {
+		t.Fatalf("expected resent vote to be accepted, got %v", err)
+	}
+	if s := tr.Stats("golang", "site"); s.Total != 1 {
+		t.Errorf("expected 1 counted vote, got %d", s.Total)
+	}
+}
@@ -1,5 +1,16 @@
 package votifier
 
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
 // TokenProvider provides a token for a given vote service.
 // The vote service is the name of the service the user is voting from
 // and sent by the vote service itself (e.g. "minecraft-serverlist.net").
@@ -22,3 +33,179 @@ func StaticTokenProvider(token string) TokenProvider {
 		return token
 	})
 }
+
+// MultiTokenProvider is a TokenProvider accepting several tokens per
+// service, e.g. the previous token during a rotation.
+type MultiTokenProvider interface {
+	TokenProvider
+	// Tokens returns the tokens accepted for a service, the current token first.
+	Tokens(service string) []string
+}
+
+// TokensOf returns the tokens p accepts for a service, the current token first.
+func TokensOf(p TokenProvider, service string) []string {
+	if m, ok := p.(MultiTokenProvider); ok {
+		return m.Tokens(service)
+	}
+	return []string{p.Token(service)}
+}
+
+// TokenID returns an identifier of a token version: the first 4 bytes of
+// the SHA-256 hash of the token, in hex. It tells which token a vote site
+// used without revealing the token.
+func TokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:4])
+}
+
+// DefaultTokenService is the service whose token a MapTokenProvider uses
+// for services without a token of their own, as in NuVotifier.
+const DefaultTokenService = "default"
+
+// MapTokenProvider is a TokenProvider with a token per service
+// that can be changed while a server is running.
+//
+// Tokens can be rotated with a grace period during which the previous
+// token is still accepted, so vote sites can switch at their own pace.
+type MapTokenProvider struct {
+	mu     sync.RWMutex
+	tokens map[string]*serviceTokens
+}
+
+type serviceTokens struct {
+	current string
+	grace   []graceToken // newest first
+}
+
+type graceToken struct {
+	token   string
+	expires time.Time
+}
+
+var _ MultiTokenProvider = (*MapTokenProvider)(nil)
+
+// NewMapTokenProvider returns a provider with a copy of the tokens per service.
+func NewMapTokenProvider(tokens map[string]string) *MapTokenProvider {
+	p := &MapTokenProvider{tokens: make(map[string]*serviceTokens, len(tokens))}
+	for service, token := range tokens {
+		p.tokens[service] = &serviceTokens{current: token}
+	}
+	return p
+}
+
+// lookupLocked returns the tokens of a service, or of DefaultTokenService
+// if the service has none.
+func (p *MapTokenProvider) lookupLocked(service string) *serviceTokens {
+	if t, ok := p.tokens[service]; ok {
+		return t
+	}
+	return p.tokens[DefaultTokenService]
+}
+
+// Token implements TokenProvider. Services without a token of their own
+// use the token of DefaultTokenService, if any.
+func (p *MapTokenProvider) Token(service string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if t := p.lookupLocked(service); t != nil {
+		return t.current
+	}
+	return ""
+}
+
+// Tokens implements MultiTokenProvider. It returns the current token and
+// the unexpired previous tokens of a service.
+func (p *MapTokenProvider) Tokens(service string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	t := p.lookupLocked(service)
+	if t == nil {
+		return nil
+	}
+	now := timeNow()
+	tokens := []string{t.current}
+	for _, g := range t.grace {
+		if now.Before(g.expires) {
+			tokens = append(tokens, g.token)
+		}
+	}
+	return tokens
+}
+
+// Set sets the token of a service, immediately replacing its previous tokens.
+func (p *MapTokenProvider) Set(service, token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tokens == nil {
+		p.tokens = map[string]*serviceTokens{}
+	}
+	p.tokens[service] = &serviceTokens{current: token}
+}
+
+// Rotate sets the token of a service and keeps accepting its current token
+// for the grace period. If the service had no token of its own, Rotate is
+// like Set.
+func (p *MapTokenProvider) Rotate(service, token string, grace time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old, ok := p.tokens[service]
+	if !ok || grace <= 0 {
+		if p.tokens == nil {
+			p.tokens = map[string]*serviceTokens{}
+		}
+		p.tokens[service] = &serviceTokens{current: token}
+		return
+	}
+	now := timeNow()
+	t := &serviceTokens{current: token, grace: []graceToken{{token: old.current, expires: now.Add(grace)}}}
+	for _, g := range old.grace {
+		if now.Before(g.expires) && g.token != token {
+			t.grace = append(t.grace, g)
+		}
+	}
+	p.tokens[service] = t
+}
+
+// AddGraceToken adds a previous token of a service that is accepted until
+// it expires, e.g. when loading a rotated token on startup. The service
+// must have a current token.
+func (p *MapTokenProvider) AddGraceToken(service, token string, expires time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.tokens[service]
+	if !ok {
+		return fmt.Errorf("service %q has no token", service)
+	}
+	t.grace = append(t.grace, graceToken{token: token, expires: expires})
+	return nil
+}
+
+// Delete removes the tokens of a service and reports whether it had any.
+func (p *MapTokenProvider) Delete(service string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.tokens[service]
+	delete(p.tokens, service)
+	return ok
+}
+
+// Services returns the sorted names of all services with a token.
+func (p *MapTokenProvider) Services() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	services := make([]string, 0, len(p.tokens))
+	for service := range p.tokens {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// NewToken generates a random token in the format NuVotifier generates them.
+func NewToken() (string, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 130))
+	if err != nil {
+		return "", err
+	}
+	return n.Text(32), nil
+}
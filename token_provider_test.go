@@ -0,0 +1,107 @@
+package votifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMapTokenProvider(t *testing.T) {
+	p := NewMapTokenProvider(map[string]string{DefaultTokenService: "default-token"})
+	p.Set("site", "site-token")
+
+	if got := p.Token("site"); got != "site-token" {
+		t.Errorf("expected service token, got %q", got)
+	}
+	if got := p.Token("other"); got != "default-token" {
+		t.Errorf("expected default token, got %q", got)
+	}
+	if got := strings.Join(p.Services(), ","); got != "default,site" {
+		t.Errorf("unexpected services %q", got)
+	}
+	if !p.Delete("site") || p.Delete("site") {
+		t.Error("expected site to be deleted exactly once")
+	}
+	if got := p.Token("site"); got != "default-token" {
+		t.Errorf("expected default token after delete, got %q", got)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewToken()
+	if a == b || len(a) < 20 {
+		t.Errorf("expected distinct random tokens, got %q and %q", a, b)
+	}
+}
+
+func TestMapTokenProviderRotate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	stubNow(t, now)
+	p := NewMapTokenProvider(map[string]string{DefaultTokenService: "default-token", "site": "v1"})
+	p.Rotate("site", "v2", time.Hour)
+	p.Rotate("site", "v3", 2*time.Hour)
+	if got := p.Token("site"); got != "v3" {
+		t.Errorf("expected the rotated token, got %q", got)
+	}
+	if got := strings.Join(p.Tokens("site"), ","); got != "v3,v2,v1" {
+		t.Errorf("expected current and previous tokens, got %q", got)
+	}
+	if got := strings.Join(p.Tokens("other"), ","); got != "default-token" {
+		t.Errorf("expected the default token, got %q", got)
+	}
+
+	stubNow(t, now.Add(90*time.Minute))
+	if got := strings.Join(p.Tokens("site"), ","); got != "v3,v2" {
+		t.Errorf("expected v1 to have expired, got %q", got)
+	}
+	if err := p.AddGraceToken("site", "v0", now.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddGraceToken("unknown", "x", now.Add(time.Hour)); err == nil {
+		t.Error("expected an error adding a grace token to a service without token")
+	}
+	if got := strings.Join(p.Tokens("site"), ","); got != "v3,v2,v0" {
+		t.Errorf("unexpected tokens %q", got)
+	}
+
+	p.Set("site", "v4")
+	if got := strings.Join(p.Tokens("site"), ","); got != "v4" {
+		t.Errorf("expected Set to drop previous tokens, got %q", got)
+	}
+	p.Rotate("new", "n1", time.Hour)
+	if got := strings.Join(p.Tokens("new"), ","); got != "n1" {
+		t.Errorf("expected rotating a new service to set its token, got %q", got)
+	}
+}
+
+func TestDecodeV2TokenID(t *testing.T) {
+	p := NewMapTokenProvider(map[string]string{"site": "old"})
+	p.Rotate("site", "new", time.Hour)
+	for _, token := range []string{"old", "new"} {
+		v := Vote{ServiceName: "site", Username: "golang"}
+		data, err := v.EncodeV2(token, "challenge")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Vote
+		id, err := decoded.DecodeV2TokenID(data, p, "challenge")
+		if err != nil {
+			t.Fatalf("token %q: %v", token, err)
+		}
+		if id != TokenID(token) || decoded.Username != "golang" {
+			t.Errorf("token %q: unexpected token ID %s", token, id)
+		}
+	}
+	v := Vote{ServiceName: "site"}
+	data, _ := v.EncodeV2("other", "challenge")
+	if err := new(Vote).DecodeV2(data, p, "challenge"); err == nil {
+		t.Error("expected an unknown token to be rejected")
+	}
+	if TokenID("old") == TokenID("new") || len(TokenID("old")) != 8 {
+		t.Errorf("unexpected token IDs %s, %s", TokenID("old"), TokenID("new"))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,8 +20,13 @@ func randomString() (string, error) {
 	return base64.RawStdEncoding.EncodeToString(p), nil
 }
 
+// dial connects to a TCP address, or a Unix socket if addr is "unix:" followed by its path.
 func dial(addr string) (net.Conn, error) {
-	conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
+	network, address := "tcp", addr
+	if path := strings.TrimPrefix(addr, "unix:"); path != addr {
+		network, address = "unix", path
+	}
+	conn, err := net.DialTimeout(network, address, 3*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
 	}
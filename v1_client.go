@@ -1,19 +1,32 @@
 package votifier
 
 import (
+	"bufio"
 	"crypto/rsa"
 	"fmt"
+	"sync"
 )
 
 // V1Client represents a Votifier v1 client.
 type V1Client struct {
 	address   string
 	publicKey *rsa.PublicKey
+
+	mu      sync.Mutex
+	version string // version advertised by the server on the last connection
 }
 
 // NewV1Client creates a new Votifier client.
 func NewV1Client(address string, publicKey *rsa.PublicKey) *V1Client {
-	return &V1Client{address, publicKey}
+	return &V1Client{address: address, publicKey: publicKey}
+}
+
+// ServerVersion returns the version the server advertised in its greeting
+// the last time a vote was sent, or an empty string if none was read yet.
+func (client *V1Client) ServerVersion() string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.version
 }
 
 // SendVote sends a vote through the client.
@@ -24,6 +37,14 @@ func (client *V1Client) SendVote(vote Vote) error {
 	}
 	defer conn.Close()
 
+	greeting, err := readGreeting(bufio.NewReader(conn))
+	if err != nil {
+		return err
+	}
+	client.mu.Lock()
+	client.version = greeting.Version
+	client.mu.Unlock()
+
 	serialized, err := vote.EncodeV1(client.publicKey)
 	if err != nil {
 		return err
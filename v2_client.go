@@ -1,17 +1,26 @@
 package votifier
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"strings"
+	"sync"
 )
 
+// maxResponseSize is the maximum size of a v2 server response we accept.
+const maxResponseSize = 4096
+
 // V2Client represents a Votifier v2 client.
 type V2Client struct {
 	address string
 	token   string
+
+	mu      sync.Mutex
+	version string // version advertised by the server on the last connection
 }
 
 type v2Response struct {
@@ -22,7 +31,15 @@ type v2Response struct {
 
 // NewV2Client creates a new Votifier v2 client.
 func NewV2Client(address string, token string) *V2Client {
-	return &V2Client{address, token}
+	return &V2Client{address: address, token: token}
+}
+
+// ServerVersion returns the version the server advertised in its greeting
+// the last time a vote was sent, or an empty string if none was read yet.
+func (client *V2Client) ServerVersion() string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.version
 }
 
 // SendVote sends a vote through the client.
@@ -32,20 +49,23 @@ func (client *V2Client) SendVote(vote Vote) error {
 		return err
 	}
 	defer conn.Close()
+	return client.sendVote(conn, vote)
+}
 
-	greeting := make([]byte, 64)
-	read, err := conn.Read(greeting)
+func (client *V2Client) sendVote(conn net.Conn, vote Vote) error {
+	rd := bufio.NewReader(conn)
+	greeting, err := readGreeting(rd)
 	if err != nil {
-		return fmt.Errorf("error reading greeting: %w", err)
+		return err
 	}
-
-	parts := bytes.Split(greeting[:read-1], []byte(" "))
-	if len(parts) != 3 {
-		return errors.New("not a v2 server")
+	client.mu.Lock()
+	client.version = greeting.Version
+	client.mu.Unlock()
+	if greeting.Challenge == "" {
+		return fmt.Errorf("not a v2 server: server advertised version %s without a challenge", greeting.Version)
 	}
-	challenge := string(parts[2])
 
-	serialized, err := vote.EncodeV2(client.token, challenge)
+	serialized, err := vote.EncodeV2(client.token, greeting.Challenge)
 	if err != nil {
 		return fmt.Errorf("error encoding vote: %w", err)
 	}
@@ -54,18 +74,9 @@ func (client *V2Client) SendVote(vote Vote) error {
 		return fmt.Errorf("failed to send vote: %w", err)
 	}
 
-	// read response
-	resBuf := make([]byte, 256)
-	read, err = conn.Read(resBuf)
-	if err != nil {
-		return fmt.Errorf("error reading response: %w", err)
-	}
-
-	var res v2Response
-	rd := bytes.NewReader(resBuf[:read])
-	err = json.NewDecoder(rd).Decode(&res)
+	res, err := readV2Response(rd)
 	if err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
+		return err
 	}
 
 	if !strings.EqualFold(res.Status, "ok") {
@@ -78,6 +89,23 @@ func (client *V2Client) SendVote(vote Vote) error {
 	return nil
 }
 
+// readV2Response reads the JSON response of a v2 server, which may or may
+// not be terminated by a line ending before the server closes the connection.
+func readV2Response(r io.Reader) (*v2Response, error) {
+	lr := &io.LimitedReader{R: r, N: maxResponseSize}
+	var res v2Response
+	if err := json.NewDecoder(lr).Decode(&res); err != nil {
+		switch {
+		case lr.N <= 0:
+			return nil, fmt.Errorf("error reading response: exceeds %d bytes", maxResponseSize)
+		case errors.Is(err, io.EOF):
+			return nil, fmt.Errorf("error reading response: server closed connection without responding")
+		}
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return &res, nil
+}
+
 type remoteError struct {
 	cause string
 	err   error
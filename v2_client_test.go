@@ -0,0 +1,109 @@
+package votifier
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// writeFragmented writes s one byte at a time to simulate a fragmented stream.
+func writeFragmented(w io.Writer, s string) error {
+	for i := 0; i < len(s); i++ {
+		if _, err := w.Write([]byte{s[i]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeV2Server serves a single vote on conn, sending greeting and response fragmented.
+func fakeV2Server(conn net.Conn, greeting, challenge, response string) error {
+	defer conn.Close()
+	if err := writeFragmented(conn, greeting); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	data := make([]byte, 4+int(binary.BigEndian.Uint16(header[2:])))
+	copy(data, header)
+	if _, err := io.ReadFull(conn, data[4:]); err != nil {
+		return err
+	}
+	var v Vote
+	if err := v.DecodeV2(data, StaticTokenProvider("abcxyz"), challenge); err != nil {
+		return err
+	}
+
+	// The client may hang up as soon as it has decoded the response.
+	_ = writeFragmented(conn, response)
+	return nil
+}
+
+func TestV2ClientFragmented(t *testing.T) {
+	longChallenge := strings.Repeat("c", 128)
+	tests := []struct {
+		name      string
+		greeting  string
+		challenge string
+		response  string
+		wantErr   string
+	}{
+		{name: "LF", greeting: "VOTIFIER 2 xyz\n", challenge: "xyz", response: `{"status":"ok"}`},
+		{name: "CRLF", greeting: "VOTIFIER 2 xyz\r\n", challenge: "xyz", response: "{\"status\":\"ok\"}\r\n"},
+		{name: "long challenge", greeting: "VOTIFIER 2 " + longChallenge + "\n", challenge: longChallenge, response: `{"status":"ok"}`},
+		{name: "error", greeting: "VOTIFIER 2 xyz\n", challenge: "xyz", response: `{"status":"error","cause":"decode","error":"bad"}` + "\n", wantErr: "decode: bad"},
+		{name: "no response", greeting: "VOTIFIER 2 xyz\n", challenge: "xyz", wantErr: "without responding"},
+		{name: "oversized response", greeting: "VOTIFIER 2 xyz\n", challenge: "xyz", response: `{"status":"` + strings.Repeat("x", maxResponseSize) + `"}`, wantErr: "exceeds"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			serverErr := make(chan error, 1)
+			go func() {
+				serverErr <- fakeV2Server(serverConn, tt.greeting, tt.challenge, tt.response)
+			}()
+			defer func() {
+				clientConn.Close()
+				if err := <-serverErr; err != nil {
+					t.Errorf("fake server: %v", err)
+				}
+			}()
+
+			client := NewV2Client("", "abcxyz")
+			err := client.sendVote(clientConn, Vote{ServiceName: "golang", Username: "golang", Address: "127.0.0.1"})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if client.ServerVersion() != "2" {
+					t.Errorf("expected server version 2, got %q", client.ServerVersion())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestV2ClientNotV2Server(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		defer serverConn.Close()
+		_ = writeFragmented(serverConn, "VOTIFIER 1.9\n")
+	}()
+
+	client := NewV2Client("", "abcxyz")
+	err := client.sendVote(clientConn, Vote{ServiceName: "golang"})
+	if err == nil || !strings.Contains(err.Error(), "not a v2 server") {
+		t.Errorf("expected not a v2 server error, got %v", err)
+	}
+}
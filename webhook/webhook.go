@@ -0,0 +1,323 @@
+// Package webhook delivers accepted votes to HTTP endpoints, such as
+// Discord webhooks or a reward API.
+//
+// By default every vote is POSTed as a JSON Payload. Endpoints can instead
+// render their body with a text/template, e.g. to post a Discord embed:
+//
+//	webhook.Endpoint{
+//		URL:      "https://discord.com/api/webhooks/...",
+//		Template: webhook.DiscordTemplate,
+//	}
+//
+// Templates are executed with the Payload as data and can use the json
+// function to embed values as escaped JSON strings.
+//
+// If a Secret is configured, the body is signed with HMAC-SHA256 and the
+// signature is sent in the X-Votifier-Signature header as "sha256=<hex>".
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/internal/signature"
+)
+
+// DiscordTemplate renders a vote as a Discord webhook message with an embed.
+const DiscordTemplate = `{"embeds":[{"title":"New vote","description":{{json (printf "%s voted on %s" .Username .ServiceName)}},"timestamp":{{json .Time}}}]}`
+
+// Payload is the JSON body posted for a vote, unless an endpoint has a template.
+type Payload struct {
+	ServiceName string            `json:"serviceName"`
+	Username    string            `json:"username"`
+	Address     string            `json:"address"`
+	Timestamp   int64             `json:"timestamp"` // Unix milliseconds
+	Protocol    votifier.Protocol `json:"protocol"`
+}
+
+// Time returns the timestamp in RFC 3339 format.
+func (p *Payload) Time() string {
+	return time.UnixMilli(p.Timestamp).UTC().Format(time.RFC3339)
+}
+
+// Endpoint is an HTTP endpoint votes are posted to.
+type Endpoint struct {
+	Name     string // Identifies the endpoint in dead letters.
+	URL      string
+	Secret   string            // Optional, signs request bodies if set.
+	Template string            // Optional text/template rendering the request body.
+	Headers  map[string]string // Optional additional request headers.
+}
+
+// DeadLetter is a vote that could not be delivered to an endpoint.
+type DeadLetter struct {
+	Endpoint Endpoint
+	Vote     votifier.Vote
+	Body     []byte
+	Err      error // Error of the last attempt.
+}
+
+// ErrListenerClosed is returned for votes posted after the Listener was closed.
+var ErrListenerClosed = errors.New("webhook listener closed")
+
+// Listener posts votes to endpoints in the background.
+//
+// Deliveries failing with a network error, a 429 or a 5xx status are retried
+// with exponential backoff, waiting at most MaxBackoff even if the endpoint
+// asks for longer with a Retry-After header. Votes that could not be
+// delivered after MaxAttempts, were rejected with another status or whose
+// retry was canceled by Close are passed to OnDeadLetter.
+type Listener struct {
+	Endpoints      []Endpoint
+	Client         *http.Client     // Defaults to a client with a 10 second timeout.
+	MaxAttempts    int              // Attempts per endpoint, defaults to 5.
+	InitialBackoff time.Duration    // Delay before the first retry, defaults to 1 second.
+	MaxBackoff     time.Duration    // Upper bound of the backoff, defaults to 1 minute.
+	MaxPending     int              // Maximum number of deliveries in the background, defaults to 100.
+	OnDeadLetter   func(DeadLetter) // Optional
+
+	once      sync.Once
+	templates []*template.Template
+	initErr   error
+	pending   chan struct{} // semaphore of deliveries in the background
+	done      chan struct{} // closed by Close
+	mu        sync.Mutex
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (l *Listener) init() error {
+	l.once.Do(func() {
+		n := l.MaxPending
+		if n <= 0 {
+			n = 100
+		}
+		l.pending = make(chan struct{}, n)
+		l.done = make(chan struct{})
+		l.templates = make([]*template.Template, len(l.Endpoints))
+		for i, e := range l.Endpoints {
+			if e.Template == "" {
+				continue
+			}
+			t, err := template.New(e.Name).Funcs(funcs).Parse(e.Template)
+			if err != nil {
+				l.initErr = fmt.Errorf("endpoint %s: invalid template: %w", e.Name, err)
+				return
+			}
+			l.templates[i] = t
+		}
+	})
+	return l.initErr
+}
+
+// VoteListener returns a listener delivering votes in the background before
+// passing them on to next, if not nil. It fails only if a template is
+// invalid or the listener is closed.
+func (l *Listener) VoteListener(next votifier.VoteListener) votifier.VoteListener {
+	return func(vote *votifier.Vote, protocol votifier.Protocol) error {
+		if err := l.Post(vote, protocol); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(vote, protocol)
+		}
+		return nil
+	}
+}
+
+// Post delivers the vote to all endpoints in the background. It blocks
+// while MaxPending deliveries are running and fails with ErrListenerClosed
+// once the listener is closed.
+func (l *Listener) Post(vote *votifier.Vote, protocol votifier.Protocol) error {
+	if err := l.init(); err != nil {
+		return err
+	}
+	payload := &Payload{
+		ServiceName: vote.ServiceName,
+		Username:    vote.Username,
+		Address:     vote.Address,
+		Timestamp:   vote.Timestamp.UnixMilli(),
+		Protocol:    protocol,
+	}
+	for i, e := range l.Endpoints {
+		body, err := l.render(i, payload)
+		if err != nil {
+			l.deadLetter(DeadLetter{Endpoint: e, Vote: *vote, Err: err})
+			continue
+		}
+		if err = l.start(); err != nil {
+			return err
+		}
+		go func(e Endpoint, v votifier.Vote) {
+			defer func() {
+				<-l.pending
+				l.wg.Done()
+			}()
+			if err := l.deliver(e, body); err != nil {
+				l.deadLetter(DeadLetter{Endpoint: e, Vote: v, Body: body, Err: err})
+			}
+		}(e, *vote)
+	}
+	return nil
+}
+
+// start reserves a slot for a delivery in the background.
+func (l *Listener) start() error {
+	select {
+	case l.pending <- struct{}{}:
+	case <-l.done:
+		return ErrListenerClosed
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		<-l.pending
+		return ErrListenerClosed
+	}
+	l.wg.Add(1)
+	return nil
+}
+
+// Wait blocks until all deliveries started so far are done.
+func (l *Listener) Wait() {
+	l.wg.Wait()
+}
+
+// Close stops accepting votes, cancels pending retries and waits for the
+// deliveries in progress. Votes whose retry was canceled are passed to
+// OnDeadLetter.
+func (l *Listener) Close() error {
+	_ = l.init()
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+	close(l.done)
+	l.wg.Wait()
+	return nil
+}
+
+func (l *Listener) render(i int, payload *Payload) ([]byte, error) {
+	if l.templates[i] == nil {
+		return json.Marshal(payload)
+	}
+	var buf bytes.Buffer
+	if err := l.templates[i].Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("error rendering template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (l *Listener) deadLetter(d DeadLetter) {
+	if l.OnDeadLetter != nil {
+		l.OnDeadLetter(d)
+	}
+}
+
+// permanentError is a delivery error that is not retried.
+type permanentError struct{ error }
+
+func (l *Listener) deliver(e Endpoint, body []byte) error {
+	attempts := l.MaxAttempts
+	if attempts <= 0 {
+		attempts = 5
+	}
+	backoff := l.InitialBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	maxBackoff := l.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = time.Minute
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		var retryAfter time.Duration
+		retryAfter, err = l.post(e, body)
+		if err == nil {
+			return nil
+		}
+		if p, ok := err.(permanentError); ok {
+			return p.error
+		}
+		if i == attempts-1 {
+			break
+		}
+		wait := backoff
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if wait > maxBackoff {
+			wait = maxBackoff
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-l.done:
+			t.Stop()
+			return fmt.Errorf("listener closed before retrying: %w", err)
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return err
+}
+
+// post sends a single request and returns how long the server asked to
+// wait before retrying, if it did.
+func (l *Listener) post(e Endpoint, body []byte) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-votifier")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	if e.Secret != "" {
+		req.Header.Set(signature.Header, signature.Sign(e.Secret, body))
+	}
+
+	client := l.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return 0, nil
+	}
+	err = fmt.Errorf("unexpected status %s", res.Status)
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+		return 0, permanentError{err}
+	}
+	seconds, _ := strconv.Atoi(res.Header.Get("Retry-After"))
+	return time.Duration(seconds) * time.Second, err
+}
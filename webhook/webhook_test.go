@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.minekube.com/votifier"
+	"go.minekube.com/votifier/internal/signature"
+)
+
+func TestListener(t *testing.T) {
+	var mu sync.Mutex
+	bodies := map[string][]string{}
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/flaky":
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/signed":
+			if !signature.Verify("secret", body, r.Header.Get(signature.Header)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "/rejected":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], string(body))
+	}))
+	defer srv.Close()
+
+	var dead []DeadLetter
+	l := &Listener{
+		Endpoints: []Endpoint{
+			{Name: "plain", URL: srv.URL + "/plain"},
+			{Name: "flaky", URL: srv.URL + "/flaky"},
+			{Name: "signed", URL: srv.URL + "/signed", Secret: "secret"},
+			{Name: "discord", URL: srv.URL + "/discord", Template: DiscordTemplate},
+			{Name: "rejected", URL: srv.URL + "/rejected"},
+		},
+		InitialBackoff: time.Millisecond,
+		OnDeadLetter: func(d DeadLetter) {
+			mu.Lock()
+			defer mu.Unlock()
+			dead = append(dead, d)
+		},
+	}
+
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	vote := &votifier.Vote{ServiceName: "site", Username: `go"lang`, Address: "127.0.0.1", Timestamp: ts}
+	if err := l.VoteListener(nil)(vote, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	l.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/plain", "/flaky", "/signed"} {
+		if len(bodies[path]) != 1 {
+			t.Errorf("expected one delivery to %s, got %d", path, len(bodies[path]))
+			continue
+		}
+		var p Payload
+		if err := json.Unmarshal([]byte(bodies[path][0]), &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Username != vote.Username || p.Timestamp != ts.UnixMilli() || p.Protocol != votifier.V2 {
+			t.Errorf("unexpected payload %+v", p)
+		}
+	}
+
+	var embed struct {
+		Embeds []struct {
+			Description string `json:"description"`
+			Timestamp   string `json:"timestamp"`
+		} `json:"embeds"`
+	}
+	if err := json.Unmarshal([]byte(bodies["/discord"][0]), &embed); err != nil {
+		t.Fatalf("invalid discord body %q: %v", bodies["/discord"][0], err)
+	}
+	if e := embed.Embeds[0]; e.Description != `go"lang voted on site` || e.Timestamp != "2024-01-01T12:00:00Z" {
+		t.Errorf("unexpected embed %+v", e)
+	}
+
+	if len(dead) != 1 || dead[0].Endpoint.Name != "rejected" || !strings.Contains(dead[0].Err.Error(), "400") {
+		t.Errorf("expected rejected endpoint in dead letters, got %+v", dead)
+	}
+}
+
+func TestListenerInvalidTemplate(t *testing.T) {
+	l := &Listener{Endpoints: []Endpoint{{Name: "broken", URL: "http://localhost", Template: "{{"}}}
+	if err := l.Post(&votifier.Vote{}, votifier.V1); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected template error, got %v", err)
+	}
+}
+
+func TestListenerRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if requests++; requests == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	l := &Listener{
+		Endpoints:      []Endpoint{{Name: "limited", URL: srv.URL}},
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	}
+	if err := l.Post(&votifier.Vote{Username: "golang"}, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		l.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Retry-After to be capped at MaxBackoff")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestListenerClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dead := make(chan DeadLetter, 1)
+	l := &Listener{
+		Endpoints:      []Endpoint{{Name: "down", URL: srv.URL}},
+		InitialBackoff: time.Hour,
+		OnDeadLetter:   func(d DeadLetter) { dead <- d },
+	}
+	if err := l.Post(&votifier.Vote{Username: "golang"}, votifier.V2); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected Close to cancel the retry, took %s", d)
+	}
+	select {
+	case d := <-dead:
+		if d.Endpoint.Name != "down" {
+			t.Errorf("unexpected dead letter %+v", d)
+		}
+	default:
+		t.Error("expected the canceled delivery to be a dead letter")
+	}
+	if err := l.Post(&votifier.Vote{Username: "golang"}, votifier.V2); !errors.Is(err, ErrListenerClosed) {
+		t.Errorf("expected ErrListenerClosed, got %v", err)
+	}
+}